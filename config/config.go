@@ -10,6 +10,31 @@ import (
 type Config struct {
 	NotesPath   string   `json:"notes_path"`
 	IgnoreDecks []string `json:"ignore_decks,omitempty"`
+
+	// Scheduler is "sm2" (default) or "fsrs".
+	Scheduler string `json:"scheduler,omitempty"`
+	// DesiredRetention is the FSRS recall target, e.g. 0.9. Ignored by SM-2.
+	DesiredRetention float64 `json:"desired_retention,omitempty"`
+
+	// Backend is "json" (default) or "sqlite".
+	Backend string `json:"backend,omitempty"`
+
+	// Include and Exclude are .gitignore-style globs, relative to the notes
+	// path, choosing which files are parsed. An empty Include parses all.
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+	// FollowSymlinks descends into symlinked directories under the notes path.
+	FollowSymlinks bool `json:"follow_symlinks,omitempty"`
+
+	// PlainText shows cards as written instead of rendering their markdown.
+	PlainText bool `json:"plain_text,omitempty"`
+	// CodeTheme is the chroma style for fenced code in cards, e.g. "monokai"
+	// or "github". Empty or unknown uses the default colours.
+	CodeTheme string `json:"code_theme,omitempty"`
+
+	// TypeAnswerDecks are decks whose answers are typed in during review,
+	// matched by prefix like IgnoreDecks.
+	TypeAnswerDecks []string `json:"type_answer_decks,omitempty"`
 }
 
 func DefaultConfigPath() string {
@@ -59,7 +84,17 @@ func (c Config) Save(path string) error {
 // IsDeckIgnored returns true if the deck name matches any entry in the ignore list.
 // Matching is done by prefix, so "leetcode" ignores "leetcode", "leetcode.dp.tasks", etc.
 func (c Config) IsDeckIgnored(deck string) bool {
-	for _, pattern := range c.IgnoreDecks {
+	return matchDeck(c.IgnoreDecks, deck)
+}
+
+// IsTypeAnswerDeck returns true if answers in the deck are typed in during review.
+func (c Config) IsTypeAnswerDeck(deck string) bool {
+	return matchDeck(c.TypeAnswerDecks, deck)
+}
+
+// matchDeck reports whether deck is one of decks, or nested under one.
+func matchDeck(decks []string, deck string) bool {
+	for _, pattern := range decks {
 		if deck == pattern || strings.HasPrefix(deck, pattern+".") {
 			return true
 		}
@@ -75,6 +75,29 @@ func TestIsDeckIgnored(t *testing.T) {
 	}
 }
 
+func TestIsTypeAnswerDeck(t *testing.T) {
+	tests := []struct {
+		name  string
+		decks []string
+		deck  string
+		want  bool
+	}{
+		{"exact match", []string{"german"}, "german", true},
+		{"nested deck", []string{"german"}, "german.verbs", true},
+		{"partial name does not match", []string{"german"}, "germanic", false},
+		{"none configured", nil, "german", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{TypeAnswerDecks: tt.decks}
+			if got := cfg.IsTypeAnswerDeck(tt.deck); got != tt.want {
+				t.Errorf("IsTypeAnswerDeck(%q) = %v, want %v", tt.deck, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestSave(t *testing.T) {
 	tmpDir := t.TempDir()
 	path := filepath.Join(tmpDir, "subdir", "config.json")
@@ -3,6 +3,7 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"sort"
 	"strings"
@@ -26,17 +27,20 @@ func main() {
 
 	// Parse args: first non-flag arg is the path, rest are flags
 	pathArg := ""
-	dueFormat := "plain"
+	format := "plain"
+	noCache := false
 	for i := 0; i < len(rest); i++ {
 		switch rest[i] {
+		case "--no-cache":
+			noCache = true
 		case "--json":
-			dueFormat = "json"
+			format = "json"
 		case "--by-deck":
-			dueFormat = "by-deck"
+			format = "by-deck"
 		case "--format":
 			if i+1 < len(rest) {
 				i++
-				dueFormat = rest[i]
+				format = rest[i]
 			}
 		default:
 			if pathArg == "" {
@@ -53,15 +57,26 @@ func main() {
 		os.Exit(1)
 	}
 
+	opts := parser.Options{
+		Include:        cfg.Include,
+		Exclude:        cfg.Exclude,
+		FollowSymlinks: cfg.FollowSymlinks,
+	}
+	if !noCache {
+		opts.Cache = parser.LoadCache(parser.DefaultCachePath())
+	}
+
 	switch cmd {
 	case "review":
-		runReview(notesPath, cfg)
+		runReview(notesPath, cfg, opts)
 	case "due":
-		runDue(notesPath, dueFormat, cfg)
+		runDue(notesPath, format, cfg, opts)
 	case "list":
-		runList(notesPath, cfg)
+		runList(notesPath, cfg, opts)
 	case "config":
-		runConfig(notesPath, cfg)
+		runConfig(notesPath, cfg, opts)
+	case "lint":
+		runLint(notesPath, format == "json", opts)
 	default:
 		printUsage()
 		os.Exit(1)
@@ -76,45 +91,187 @@ func printUsage() {
 	fmt.Fprintln(os.Stderr, "  due     Print count of due cards (for polybar)")
 	fmt.Fprintln(os.Stderr, "  list    List decks and card counts")
 	fmt.Fprintln(os.Stderr, "  config  Configure deck ignore list")
+	fmt.Fprintln(os.Stderr, "  lint    Report card syntax problems; exits 1 if there are any")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Flags:")
+	fmt.Fprintln(os.Stderr, "  --no-cache        Re-parse every note instead of using the parse cache")
 	fmt.Fprintln(os.Stderr, "")
 	fmt.Fprintln(os.Stderr, "Due flags:")
 	fmt.Fprintln(os.Stderr, "  --json            JSON output with full stats")
 	fmt.Fprintln(os.Stderr, "  --format polybar  One-liner with new/overdue breakdown")
 	fmt.Fprintln(os.Stderr, "  --by-deck         Due counts per deck")
 	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Lint flags:")
+	fmt.Fprintln(os.Stderr, "  --json            JSON array of diagnostics")
+	fmt.Fprintln(os.Stderr, "")
 	fmt.Fprintf(os.Stderr, "Path is optional if notes_path is set in %s\n", config.DefaultConfigPath())
 }
 
+// parseCards parses the notes under path and saves the parse cache, if any.
+func parseCards(path string, opts parser.Options) ([]parser.Card, []parser.Diagnostic, error) {
+	cards, diags, err := parser.ParseDirectoryWith(path, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if opts.Cache != nil {
+		// a cache that can't be written only costs speed next time
+		_ = opts.Cache.Save()
+	}
+	return cards, diags, nil
+}
+
 func filterIgnored(cards []parser.Card, cfg config.Config) []parser.Card {
 	var filtered []parser.Card
 	for _, c := range cards {
-		if !cfg.IsDeckIgnored(c.DeckName) {
+		if !cfg.IsDeckIgnored(c.DeckName) && !c.Meta.Suspend {
 			filtered = append(filtered, c)
 		}
 	}
 	return filtered
 }
 
-func runReview(path string, cfg config.Config) {
-	cards, err := parser.ParseDirectory(path)
+// loadStore opens the configured backend, applies the configured scheduler and
+// reconciles stored history with the parsed cards (before deck filtering,
+// so ignored cards aren't mistaken for orphans).
+func loadStore(cfg config.Config, cards []parser.Card) (*storage.Store, error) {
+	var store *storage.Store
+	var err error
+	if cfg.Backend == "sqlite" {
+		store, err = storage.OpenSQLite(storage.DefaultDBPath(), storage.DefaultPath())
+	} else {
+		store, err = storage.Load(storage.DefaultPath())
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Scheduler == "fsrs" {
+		store.UseFSRS(cfg.DesiredRetention)
+	}
+	store.Reconcile(cardInfos(cards))
+	for _, c := range cards {
+		if sched := cardScheduler(cfg, c.Meta); sched != nil {
+			store.SetCardScheduler(c.Key(), sched)
+		}
+	}
+	return store, nil
+}
+
+// reloadFile re-parses file, edited during a review, into all, the
+// unfiltered cards under root. Its cards keep their schedules even if
+// their keys changed: Reconcile matches reworded questions first, and a
+// card it leaves without state takes over that of the card whose key went
+// away at the same place in the file, as the review carries on with it.
+func reloadFile(all []parser.Card, root, file string, cfg config.Config, store *storage.Store) ([]parser.Card, error) {
+	cards, _, err := parser.ReplaceFile(all, root, file)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cards first rated this session only now get their question and file
+	// recorded, which is how Reconcile recognises the rest after an edit.
+	store.Reconcile(cardInfos(all))
+	store.Reconcile(cardInfos(cards))
+
+	// Rekey skips cards Reconcile already matched and old cards whose
+	// state it already handed on.
+	rekeyed := false
+	oldKeys := make(map[string]bool)
+	oldAt := make(map[int]string) // key of the file's old card at each position
+	for _, c := range all {
+		oldKeys[c.Key()] = true
+		if c.SourceFile == file {
+			oldAt[c.Position] = c.Key()
+		}
+	}
+	newKeys := make(map[string]bool)
+	for _, c := range cards {
+		newKeys[c.Key()] = true
+	}
+	for _, c := range cards {
+		if c.SourceFile != file || oldKeys[c.Key()] {
+			continue
+		}
+		if old, ok := oldAt[c.Position]; ok && !newKeys[old] {
+			rekeyed = store.Rekey(old, c.Key()) || rekeyed
+		}
+	}
+	if rekeyed {
+		store.Reconcile(cardInfos(cards)) // record their new questions
+	}
+	for _, c := range cards {
+		if c.SourceFile != file {
+			continue
+		}
+		if sched := cardScheduler(cfg, c.Meta); sched != nil {
+			store.SetCardScheduler(c.Key(), sched)
+		}
+	}
+	return cards, nil
+}
+
+// cardScheduler returns the scheduler a file's frontmatter asks for, or nil
+// if it doesn't override the configured one.
+func cardScheduler(cfg config.Config, meta parser.Meta) storage.Scheduler {
+	if meta.Scheduler == "" && meta.DesiredRetention == 0 {
+		return nil
+	}
+	name := meta.Scheduler
+	if name == "" {
+		name = cfg.Scheduler
+	}
+	retention := meta.DesiredRetention
+	if retention == 0 {
+		retention = cfg.DesiredRetention
+	}
+	if name == "fsrs" {
+		return storage.NewFSRSScheduler(retention)
+	}
+	return storage.SM2Scheduler{}
+}
+
+// cardInfos describes parsed cards for storage.Store.Reconcile.
+func cardInfos(cards []parser.Card) []storage.CardInfo {
+	infos := make([]storage.CardInfo, len(cards))
+	for i, c := range cards {
+		infos[i] = storage.CardInfo{
+			Key:        c.Key(),
+			Question:   c.Question,
+			SourceFile: c.SourceFile,
+			Position:   c.Position,
+		}
+	}
+	return infos
+}
+
+func runReview(path string, cfg config.Config, opts parser.Options) {
+	cards, _, err := parseCards(path, opts)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error parsing cards: %v\n", err)
 		os.Exit(1)
 	}
-	cards = filterIgnored(cards, cfg)
 
-	store, err := storage.Load(storage.DefaultPath())
+	store, err := loadStore(cfg, cards)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading state: %v\n", err)
 		os.Exit(1)
 	}
+	defer func() { _ = store.Close() }()
+	all := cards
+	cards = filterIgnored(cards, cfg)
 
 	if len(cards) == 0 {
 		fmt.Println("No flashcards found.")
 		return
 	}
 
-	model := tui.New(cards, store)
+	model := tui.New(cards, store, cfg).WithReload(func(file string) ([]parser.Card, error) {
+		reloaded, err := reloadFile(all, path, file, cfg, store)
+		if err != nil {
+			return nil, err
+		}
+		all = reloaded
+		return filterIgnored(all, cfg), nil
+	})
 	p := tea.NewProgram(model)
 	finalModel, err := p.Run()
 	if err != nil {
@@ -130,19 +287,20 @@ func runReview(path string, cfg config.Config) {
 	}
 }
 
-func runDue(path string, format string, cfg config.Config) {
-	cards, err := parser.ParseDirectory(path)
+func runDue(path string, format string, cfg config.Config, opts parser.Options) {
+	cards, _, err := parseCards(path, opts)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error parsing cards: %v\n", err)
 		os.Exit(1)
 	}
-	cards = filterIgnored(cards, cfg)
 
-	store, err := storage.Load(storage.DefaultPath())
+	store, err := loadStore(cfg, cards)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading state: %v\n", err)
 		os.Exit(1)
 	}
+	defer func() { _ = store.Close() }()
+	cards = filterIgnored(cards, cfg)
 
 	// Compute stats
 	var questions []string
@@ -155,7 +313,7 @@ func runDue(path string, format string, cfg config.Config) {
 
 	totalDue, totalNew, totalOverdue := 0, 0, 0
 	for _, c := range cards {
-		questions = append(questions, c.Question)
+		questions = append(questions, c.Key())
 
 		ds, ok := decks[c.DeckName]
 		if !ok {
@@ -164,15 +322,15 @@ func runDue(path string, format string, cfg config.Config) {
 		}
 		ds.Total++
 
-		if store.IsDue(c.Question) {
+		if store.IsDue(c.Key()) {
 			totalDue++
 			ds.Due++
 		}
-		if store.IsNew(c.Question) {
+		if store.IsNew(c.Key()) {
 			totalNew++
 			ds.New++
 		}
-		if store.IsOverdue(c.Question) {
+		if store.IsOverdue(c.Key()) {
 			totalOverdue++
 		}
 	}
@@ -237,8 +395,8 @@ func runDue(path string, format string, cfg config.Config) {
 	}
 }
 
-func runConfig(path string, cfg config.Config) {
-	cards, err := parser.ParseDirectory(path)
+func runConfig(path string, cfg config.Config, opts parser.Options) {
+	cards, _, err := parseCards(path, opts)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error parsing cards: %v\n", err)
 		os.Exit(1)
@@ -283,19 +441,20 @@ func runConfig(path string, cfg config.Config) {
 	}
 }
 
-func runList(path string, cfg config.Config) {
-	cards, err := parser.ParseDirectory(path)
+func runList(path string, cfg config.Config, opts parser.Options) {
+	cards, _, err := parseCards(path, opts)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error parsing cards: %v\n", err)
 		os.Exit(1)
 	}
-	cards = filterIgnored(cards, cfg)
 
-	store, err := storage.Load(storage.DefaultPath())
+	store, err := loadStore(cfg, cards)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading state: %v\n", err)
 		os.Exit(1)
 	}
+	defer func() { _ = store.Close() }()
+	cards = filterIgnored(cards, cfg)
 
 	// Group by deck
 	decks := make(map[string]struct {
@@ -306,7 +465,7 @@ func runList(path string, cfg config.Config) {
 	for _, c := range cards {
 		d := decks[c.DeckName]
 		d.total++
-		if store.IsDue(c.Question) {
+		if store.IsDue(c.Key()) {
 			d.due++
 		}
 		decks[c.DeckName] = d
@@ -328,4 +487,59 @@ func runList(path string, cfg config.Config) {
 		totalDue += d.due
 	}
 	fmt.Printf("%-30s %3d cards  (%d due)\n", "TOTAL", totalCards, totalDue)
+
+	if dups := parser.Duplicates(cards); len(dups) > 0 {
+		fmt.Println()
+		fmt.Println("Duplicate questions (each copy is scheduled separately):")
+		for _, group := range dups {
+			fmt.Printf("  %q\n", firstLine(group[0].Question))
+			for _, c := range group {
+				fmt.Printf("    %-40s %s\n", c.Location(), c.Qualifier)
+			}
+		}
+	}
+}
+
+// firstLine returns the first line of a possibly multi-line question.
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+func runLint(path string, asJSON bool, opts parser.Options) {
+	_, diags, err := parseCards(path, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing cards: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := printDiagnostics(os.Stdout, diags, asJSON); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing diagnostics: %v\n", err)
+		os.Exit(1)
+	}
+	if len(diags) > 0 {
+		os.Exit(1)
+	}
+}
+
+// printDiagnostics writes one "file:line: severity: message" line per
+// diagnostic, or a JSON array of them.
+func printDiagnostics(w io.Writer, diags []parser.Diagnostic, asJSON bool) error {
+	if asJSON {
+		if diags == nil {
+			diags = []parser.Diagnostic{}
+		}
+		data, err := json.Marshal(diags)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	}
+	for _, d := range diags {
+		if _, err := fmt.Fprintln(w, d); err != nil {
+			return err
+		}
+	}
+	return nil
 }
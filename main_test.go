@@ -1,10 +1,14 @@
 package main
 
 import (
+	"bytes"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/michal-franc/ankies-franc/config"
 	"github.com/michal-franc/ankies-franc/parser"
+	"github.com/michal-franc/ankies-franc/storage"
 )
 
 func TestFilterIgnored(t *testing.T) {
@@ -65,6 +69,15 @@ func TestFilterIgnored(t *testing.T) {
 			wantCount:   2,
 			wantDecks:   []string{"golang", "math"},
 		},
+		{
+			name: "drops suspended cards",
+			cards: []parser.Card{
+				{DeckName: "golang", Question: "Q1"},
+				{DeckName: "math", Question: "Q2", Meta: parser.Meta{Suspend: true}},
+			},
+			wantCount: 1,
+			wantDecks: []string{"golang"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -84,3 +97,147 @@ func TestFilterIgnored(t *testing.T) {
 		})
 	}
 }
+
+func TestCardScheduler(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Config
+		meta parser.Meta
+		want storage.Scheduler
+	}{
+		{
+			name: "no override",
+			cfg:  config.Config{Scheduler: "fsrs"},
+			want: nil,
+		},
+		{
+			name: "file switches to fsrs",
+			meta: parser.Meta{Scheduler: "fsrs", DesiredRetention: 0.85},
+			want: storage.FSRSScheduler{Retention: 0.85},
+		},
+		{
+			name: "file switches to sm2",
+			cfg:  config.Config{Scheduler: "fsrs"},
+			meta: parser.Meta{Scheduler: "sm2"},
+			want: storage.SM2Scheduler{},
+		},
+		{
+			name: "retention only keeps configured scheduler",
+			cfg:  config.Config{Scheduler: "fsrs", DesiredRetention: 0.9},
+			meta: parser.Meta{DesiredRetention: 0.95},
+			want: storage.FSRSScheduler{Retention: 0.95},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cardScheduler(tt.cfg, tt.meta); got != tt.want {
+				t.Errorf("cardScheduler() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrintDiagnostics(t *testing.T) {
+	diags := []parser.Diagnostic{
+		{File: "go.md", Line: 3, Severity: parser.SeverityWarning, Message: "card has no answer"},
+		{File: "bad.md", Severity: parser.SeverityError, Message: "permission denied"},
+	}
+
+	tests := []struct {
+		name   string
+		diags  []parser.Diagnostic
+		asJSON bool
+		want   string
+	}{
+		{
+			name:  "plain",
+			diags: diags,
+			want:  "go.md:3: warning: card has no answer\nbad.md: error: permission denied\n",
+		},
+		{
+			name:   "json",
+			diags:  diags,
+			asJSON: true,
+			want:   `[{"file":"go.md","line":3,"severity":"warning","message":"card has no answer"},{"file":"bad.md","severity":"error","message":"permission denied"}]` + "\n",
+		},
+		{
+			name:   "json with nothing to report",
+			asJSON: true,
+			want:   "[]\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := printDiagnostics(&buf, tt.diags, tt.asJSON); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("got %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestReloadFile(t *testing.T) {
+	const nilCard = "\nWhat is nil?\n?\nZero value.\n"
+	tests := []struct {
+		name   string
+		edited string
+		want   []string // question before the edit whose schedule each card keeps
+	}{
+		{"small edit", "#flashcards/go\n\nWhat does `defer` do?\n?\nRuns at function return.\n" + nilCard,
+			[]string{"What does defer do?", "What is nil?"}},
+		{"rewritten question", "#flashcards/go\n\nWhen do deferred calls run?\n?\nRuns at function return.\n" + nilCard,
+			[]string{"What does defer do?", "What is nil?"}},
+		{"deleted card and reworded one", "#flashcards/go\n\nWhat is `nil`?\n?\nZero value.\n",
+			[]string{"What is nil?"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			file := filepath.Join(dir, "go.md")
+			write := func(content string) {
+				t.Helper()
+				if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			write("#flashcards/go\n\nWhat does defer do?\n?\nRuns later.\n" + nilCard)
+
+			cards, err := parser.ParseDirectory(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			store, err := storage.Load(filepath.Join(t.TempDir(), "state.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			store.Reconcile(cardInfos(cards))
+			store.Rate(cards[0].Key(), storage.Easy)
+			store.Rate(cards[1].Key(), storage.Hard)
+			before := make(map[string]storage.CardState)
+			for _, c := range cards {
+				before[c.Question] = store.GetState(c.Key())
+			}
+
+			write(tt.edited)
+			cards, err = reloadFile(cards, dir, file, config.Config{}, store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cards) != len(tt.want) {
+				t.Fatalf("got %d cards, want %d: %+v", len(cards), len(tt.want), cards)
+			}
+			for i, q := range tt.want {
+				want := before[q]
+				if got := store.GetState(cards[i].Key()); got.Interval != want.Interval || !got.NextReview.Equal(want.NextReview) {
+					t.Errorf("card %q has state %+v, want the schedule of %q %+v", cards[i].Question, got, q, want)
+				}
+			}
+		})
+	}
+}
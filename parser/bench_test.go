@@ -0,0 +1,75 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeVault fills dir with the given number of markdown notes spread over subdirectories,
+// each with a handful of cards of every kind.
+func writeVault(b *testing.B, dir string, files int) {
+	b.Helper()
+	var note strings.Builder
+	note.WriteString("---\ntags: [flashcards/bench]\n---\n# Notes\n\nSome intro text.\n\n")
+	for i := range 10 {
+		fmt.Fprintf(&note, "Question %d?\n?\nAnswer %d, with a second\nline.\n\n", i, i)
+	}
+	note.WriteString("```go\nx := 1 // not a card ? \n```\n\nterm::definition\n\nGo was released in ==2009==.\n")
+
+	for i := range files {
+		path := filepath.Join(dir, fmt.Sprintf("d%02d", i%50), fmt.Sprintf("note%05d.md", i))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			b.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(note.String()), 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseDirectory(b *testing.B) {
+	dir := b.TempDir()
+	writeVault(b, dir, 2000)
+
+	for _, workers := range []int{1, 4, 0} {
+		name := fmt.Sprintf("workers=%d", workers)
+		if workers == 0 {
+			name = "workers=GOMAXPROCS"
+		}
+		b.Run(name, func(b *testing.B) {
+			for b.Loop() {
+				if _, _, err := ParseDirectoryWith(dir, Options{Workers: workers}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+
+	b.Run("cached", func(b *testing.B) {
+		cache := LoadCache(filepath.Join(b.TempDir(), "cache.json"))
+		if _, _, err := ParseDirectoryWith(dir, Options{Cache: cache}); err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for b.Loop() {
+			if _, _, err := ParseDirectoryWith(dir, Options{Cache: cache}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkParseFile(b *testing.B) {
+	dir := b.TempDir()
+	writeVault(b, dir, 1)
+	path := filepath.Join(dir, "d00", "note00000.md")
+
+	for b.Loop() {
+		if _, _, err := parseFile(path); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package parser
+
+import "strings"
+
+// verbatimLines marks the lines whose content must not be read as card
+// syntax: fenced code (``` or ~~~) and $$ math blocks including their
+// delimiters, HTML comments, and indented code blocks. A "?" or a blank line
+// inside them is just part of the card text.
+func verbatimLines(lines []string) []bool {
+	verbatim := make([]bool, len(lines))
+
+	fence := ""     // closing fence we are waiting for, "" when outside
+	inMath := false // inside $$ ... $$
+	inComment := false
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case fence != "":
+			verbatim[i] = true
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+				fence = ""
+			}
+			continue
+
+		case inMath:
+			verbatim[i] = true
+			if strings.HasSuffix(trimmed, "$$") {
+				inMath = false
+			}
+			continue
+
+		case inComment:
+			verbatim[i] = true
+			if strings.Contains(trimmed, "-->") {
+				inComment = false
+			}
+			continue
+		}
+
+		if f := openingFence(line); f != "" {
+			verbatim[i] = true
+			fence = f
+			continue
+		}
+		if strings.HasPrefix(trimmed, "$$") {
+			verbatim[i] = true
+			// a one-line $$...$$ block closes itself
+			inMath = len(trimmed) < 4 || !strings.HasSuffix(trimmed, "$$")
+			continue
+		}
+		if strings.HasPrefix(trimmed, "<!--") {
+			verbatim[i] = true
+			inComment = !strings.Contains(trimmed[4:], "-->")
+			continue
+		}
+	}
+
+	markIndentedCode(lines, verbatim)
+	return verbatim
+}
+
+// openingFence returns the fence a line opens (e.g. "```" or "~~~~"), or "".
+// Like CommonMark, the fence may be indented by up to three spaces.
+func openingFence(line string) string {
+	indent := len(line) - len(strings.TrimLeft(line, " "))
+	if indent > 3 {
+		return ""
+	}
+	rest := line[indent:]
+	for _, ch := range []string{"`", "~"} {
+		n := len(rest) - len(strings.TrimLeft(rest, ch))
+		if n >= 3 {
+			return strings.Repeat(ch, n)
+		}
+	}
+	return ""
+}
+
+// markIndentedCode marks indented code blocks: lines indented by a tab or
+// four spaces that follow a blank line, plus blank lines between them.
+func markIndentedCode(lines []string, verbatim []bool) {
+	for i := 0; i < len(lines); i++ {
+		if verbatim[i] || !isIndentedCode(lines[i]) || (i > 0 && strings.TrimSpace(lines[i-1]) != "") {
+			continue
+		}
+		end := i
+		for j := i; j < len(lines) && !verbatim[j]; j++ {
+			if isIndentedCode(lines[j]) {
+				end = j + 1
+			} else if strings.TrimSpace(lines[j]) != "" {
+				break
+			}
+		}
+		for j := i; j < end; j++ {
+			verbatim[j] = true
+		}
+		i = end
+	}
+}
+
+func isIndentedCode(line string) bool {
+	return strings.TrimSpace(line) != "" && (strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "    "))
+}
+
+// isBlank reports whether line i separates blocks: blank and not inside a
+// verbatim block.
+func isBlank(lines []string, verbatim []bool, i int) bool {
+	return !verbatim[i] && strings.TrimSpace(lines[i]) == ""
+}
@@ -0,0 +1,154 @@
+package parser
+
+import "testing"
+
+func TestVerbatimLines(t *testing.T) {
+	lines := []string{
+		"text",          // 0
+		"```go",         // 1
+		"?",             // 2
+		"",              // 3
+		"```",           // 4
+		"$$",            // 5
+		"x = ?",         // 6
+		"$$",            // 7
+		"$$ a + b $$",   // 8
+		"<!--",          // 9
+		"?",             // 10
+		"-->",           // 11
+		"",              // 12
+		"    code",      // 13
+		"",              // 14
+		"    more code", // 15
+		"after",         // 16
+		"~~~~",          // 17
+		"~~~",           // 18
+		"~~~~",          // 19
+		"?",             // 20
+	}
+	want := []bool{
+		false,
+		true, true, true, true,
+		true, true, true,
+		true,
+		true, true, true,
+		false,
+		true, true, true,
+		false,
+		true, true, true,
+		false,
+	}
+
+	got := verbatimLines(lines)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d (%q): verbatim = %v, want %v", i, lines[i], got[i], want[i])
+		}
+	}
+}
+
+func TestExtractCardsVerbatimBlocks(t *testing.T) {
+	t.Run("separator and blank line inside code fence", func(t *testing.T) {
+		lines := []string{
+			"What does this print?",
+			"```go",
+			"x := cond ? 1 : 2",
+			"?",
+			"",
+			"fmt.Println(x)",
+			"```",
+			"?",
+			"It doesn't compile",
+		}
+
+		cards, _ := extractCards(lines, "go", "go.md")
+		if len(cards) != 1 {
+			t.Fatalf("got %d cards, want 1", len(cards))
+		}
+		wantQ := "What does this print?\n```go\nx := cond ? 1 : 2\n?\n\nfmt.Println(x)\n```"
+		if cards[0].Question != wantQ {
+			t.Errorf("question = %q, want %q", cards[0].Question, wantQ)
+		}
+		if cards[0].Answer != "It doesn't compile" {
+			t.Errorf("answer = %q", cards[0].Answer)
+		}
+	})
+
+	t.Run("multi-paragraph code in answer", func(t *testing.T) {
+		lines := []string{
+			"Show a select loop",
+			"?",
+			"```go",
+			"for {",
+			"",
+			"\tselect {}",
+			"}",
+			"```",
+			"",
+			"Next question",
+			"?",
+			"Next answer",
+		}
+
+		cards, _ := extractCards(lines, "go", "go.md")
+		if len(cards) != 2 {
+			t.Fatalf("got %d cards, want 2", len(cards))
+		}
+		if cards[0].Answer != "```go\nfor {\n\n\tselect {}\n}\n```" {
+			t.Errorf("answer = %q", cards[0].Answer)
+		}
+		if cards[1].Question != "Next question" {
+			t.Errorf("second question = %q", cards[1].Question)
+		}
+	})
+
+	t.Run("math and comments hide separators", func(t *testing.T) {
+		lines := []string{
+			"Solve",
+			"$$",
+			"?",
+			"$$",
+			"?",
+			"x = 1",
+			"",
+			"<!-- draft",
+			"Hidden",
+			"?",
+			"-->",
+		}
+
+		cards, _ := extractCards(lines, "math", "math.md")
+		if len(cards) != 1 {
+			t.Fatalf("got %d cards, want 1", len(cards))
+		}
+		if cards[0].Question != "Solve\n$$\n?\n$$" {
+			t.Errorf("question = %q", cards[0].Question)
+		}
+	})
+
+	t.Run("no clozes or inline cards from code", func(t *testing.T) {
+		lines := []string{
+			"#flashcards",
+			"```cpp",
+			"std::vector<int> v;",
+			"if (a == b == c) {}",
+			"```",
+		}
+
+		if cards, _ := extractCards(lines, "cpp", "cpp.md"); len(cards) != 0 {
+			t.Errorf("got %d cards from a code block, want 0", len(cards))
+		}
+	})
+}
+
+func TestFindDeckIgnoresCode(t *testing.T) {
+	lines := []string{
+		"```",
+		"#flashcards/wrong",
+		"```",
+		"#flashcards/right",
+	}
+	if got := findDeck(lines); got != "right" {
+		t.Errorf("findDeck() = %q, want %q", got, "right")
+	}
+}
@@ -0,0 +1,179 @@
+package parser
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Version identifies the parser's output format. Bump it whenever a change
+// would parse the same file into different cards, so cached results from
+// older builds are thrown away.
+const Version = 8
+
+// Cache remembers the cards parsed from each file, so unchanged files are
+// not read again. A file counts as unchanged while its size and
+// modification time are. It is safe for concurrent use.
+type Cache struct {
+	path string
+
+	mu    sync.Mutex
+	files map[string]cacheEntry
+	dirty bool
+}
+
+type cacheEntry struct {
+	ModTime     time.Time    `json:"mtime"`
+	Size        int64        `json:"size"`
+	Cards       []Card       `json:"cards"`
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+}
+
+type cacheFile struct {
+	Version int                   `json:"version"`
+	Files   map[string]cacheEntry `json:"files"`
+}
+
+// DefaultCachePath returns the cache file under $XDG_CACHE_HOME.
+func DefaultCachePath() string {
+	cacheDir := os.Getenv("XDG_CACHE_HOME")
+	if cacheDir == "" {
+		home, _ := os.UserHomeDir()
+		cacheDir = filepath.Join(home, ".cache")
+	}
+	return filepath.Join(cacheDir, "ankies-franc", "parse-cache.json")
+}
+
+// LoadCache reads the cache at path. A missing, unreadable or outdated
+// cache file gives an empty cache; it is only ever a speed-up.
+func LoadCache(path string) *Cache {
+	c := &Cache{path: path, files: make(map[string]cacheEntry)}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c
+	}
+	var f cacheFile
+	if err := json.Unmarshal(data, &f); err != nil || f.Version != Version || f.Files == nil {
+		return c
+	}
+	c.files = f.Files
+	return c
+}
+
+// Save writes the cache back if anything changed since it was loaded.
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+
+	data, err := json.Marshal(cacheFile{Version: Version, Files: c.files})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+
+	// Write and rename, so a concurrent run never reads half a cache.
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+// get returns the cached entry for path if the file still has the given
+// size and modification time.
+func (c *Cache) get(path string, info os.FileInfo) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.files[cacheKey(path)]
+	if !ok || entry.Size != info.Size() || !entry.ModTime.Equal(info.ModTime()) {
+		return cacheEntry{}, false
+	}
+	return entry, true
+}
+
+func (c *Cache) put(path string, info os.FileInfo, cards []Card, diags []Diagnostic) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files[cacheKey(path)] = cacheEntry{ModTime: info.ModTime(), Size: info.Size(), Cards: cards, Diagnostics: diags}
+	c.dirty = true
+}
+
+// prune forgets files under root that a walk of root no longer found.
+func (c *Cache) prune(root string, found []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keep := make(map[string]bool, len(found))
+	for _, path := range found {
+		keep[cacheKey(path)] = true
+	}
+	prefix := strings.TrimSuffix(cacheKey(root), string(filepath.Separator)) + string(filepath.Separator)
+	for path := range c.files {
+		if strings.HasPrefix(path, prefix) && !keep[path] {
+			delete(c.files, path)
+			c.dirty = true
+		}
+	}
+}
+
+// cacheKey is the absolute form of path, so the same file is found again
+// whichever way the notes root was spelled.
+func cacheKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// parseFileCached parses path, or returns its cached cards and diagnostics
+// if the file has not changed. A nil cache always parses.
+func parseFileCached(path string, cache *Cache) ([]Card, []Diagnostic, error) {
+	if cache == nil {
+		return parseFile(path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if entry, ok := cache.get(path, info); ok {
+		cards, diags := entry.Cards, entry.Diagnostics
+		if len(cards) > 0 && cards[0].SourceFile != path || len(diags) > 0 && diags[0].File != path {
+			// cached under another spelling of the root
+			cards, diags = slices.Clone(cards), slices.Clone(diags)
+			for i := range cards {
+				cards[i].SourceFile = path
+			}
+			for i := range diags {
+				diags[i].File = path
+			}
+		}
+		return cards, diags, nil
+	}
+	cards, diags, err := parseFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	cache.put(path, info, cards, diags)
+	return cards, diags, nil
+}
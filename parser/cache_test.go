@@ -0,0 +1,180 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// writeNote writes a one-card note and pins its mtime, so tests control
+// exactly what the cache sees.
+func writeNote(t *testing.T, path, question string, mtime time.Time) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("#flashcards\n\n"+question+"\n?\nA\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func questions(cards []Card) []string {
+	var qs []string
+	for _, c := range cards {
+		qs = append(qs, c.Question)
+	}
+	return qs
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	mtime := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		change func(t *testing.T, note string)
+		want   []string
+	}{
+		{
+			name:   "unchanged file is served from cache",
+			change: func(t *testing.T, note string) {},
+			want:   []string{"Cached?"},
+		},
+		{
+			name: "size change reparses",
+			change: func(t *testing.T, note string) {
+				writeNote(t, note, "Longer question?", mtime)
+			},
+			want: []string{"Longer question?"},
+		},
+		{
+			name: "same size but newer mtime reparses",
+			change: func(t *testing.T, note string) {
+				writeNote(t, note, "Edited1?", mtime.Add(time.Second))
+			},
+			want: []string{"Edited1?"},
+		},
+		{
+			name: "deleted file is dropped",
+			change: func(t *testing.T, note string) {
+				if err := os.Remove(note); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			note := filepath.Join(dir, "notes", "go.md")
+			writeNote(t, note, "Orig123?", mtime)
+
+			cache := LoadCache(filepath.Join(dir, "cache.json"))
+			if _, _, err := ParseDirectoryWith(filepath.Join(dir, "notes"), Options{Cache: cache}); err != nil {
+				t.Fatal(err)
+			}
+			// Tamper with the entry: a cache hit returns this instead of the file.
+			entry := cache.files[note]
+			entry.Cards = []Card{{Question: "Cached?"}}
+			cache.files[note] = entry
+
+			tt.change(t, note)
+			cards, _, err := ParseDirectoryWith(filepath.Join(dir, "notes"), Options{Cache: cache})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := questions(cards); !slices.Equal(got, tt.want) {
+				t.Errorf("questions = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacheSaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	note := filepath.Join(dir, "notes", "go.md")
+	writeNote(t, note, "What is Go?", time.Now())
+	path := filepath.Join(dir, "cache", "cache.json")
+
+	cache := LoadCache(path)
+	want, _, err := ParseDirectoryWith(filepath.Join(dir, "notes"), Options{Cache: cache})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := LoadCache(path)
+	if len(reloaded.files) != 1 {
+		t.Fatalf("reloaded %d files, want 1", len(reloaded.files))
+	}
+	info, err := os.Stat(note)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, ok := reloaded.get(note, info)
+	if !ok {
+		t.Fatal("reloaded cache missed an unchanged file")
+	}
+	if got := entry.Cards; !slices.Equal(questions(got), questions(want)) || got[0].QuestionLines != want[0].QuestionLines {
+		t.Errorf("cached cards = %+v, want %+v", got, want)
+	}
+}
+
+func TestLoadCacheDiscards(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "missing file"},
+		{name: "corrupt file", content: "{not json"},
+		{name: "other parser version", content: `{"version": 0, "files": {"/x.md": {"size": 1}}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cache.json")
+			if tt.content != "" {
+				if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if cache := LoadCache(path); len(cache.files) != 0 {
+				t.Errorf("loaded %d files, want an empty cache", len(cache.files))
+			}
+		})
+	}
+}
+
+func TestCachePruneKeepsOtherRoots(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, filepath.Join(dir, "a", "x.md"), "A?", time.Now())
+	writeNote(t, filepath.Join(dir, "ab", "y.md"), "B?", time.Now())
+
+	cache := LoadCache(filepath.Join(dir, "cache.json"))
+	for _, root := range []string{"a", "ab"} {
+		if _, _, err := ParseDirectoryWith(filepath.Join(dir, root), Options{Cache: cache}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Remove(filepath.Join(dir, "a", "x.md")); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ParseDirectoryWith(filepath.Join(dir, "a"), Options{Cache: cache}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := cache.files[filepath.Join(dir, "a", "x.md")]; ok {
+		t.Error("deleted file still cached")
+	}
+	if _, ok := cache.files[filepath.Join(dir, "ab", "y.md")]; !ok {
+		t.Error("file under a sibling root was pruned")
+	}
+}
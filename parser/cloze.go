@@ -0,0 +1,135 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// {{c1::answer}} or {{c1::answer::hint}}
+	ankiClozeRe = regexp.MustCompile(`\{\{c(\d+)::(.*?)(?:::(.*?))?\}\}`)
+	// ==answer==
+	highlightClozeRe = regexp.MustCompile(`==([^=\n]+)==`)
+	// trailing Obsidian block ID: "... ^my-id"
+	blockIDRe = regexp.MustCompile(`\s\^([A-Za-z0-9_-]+)$`)
+)
+
+// clozeBlank replaces hidden text in a cloze question.
+const clozeBlank = "[...]"
+
+func hasAnkiCloze(text string) bool {
+	return ankiClozeRe.MatchString(text)
+}
+
+// paragraphAt returns the block of non-blank lines starting at line i,
+// stopping before any verbatim block.
+func paragraphAt(lines []string, verbatim []bool, i int) string {
+	end := i
+	for end < len(lines) && !verbatim[end] && strings.TrimSpace(lines[end]) != "" {
+		end++
+	}
+	return strings.Join(lines[i:end], "\n")
+}
+
+// extractClozes turns every paragraph outside the covered ? cards that holds
+// cloze deletions into one card per cloze group. Anki-style deletions group
+// by number, so all {{c1::...}} go on one card; each ==highlight== is its
+// own card. The question shows the group's deletions as blanks and the
+// answer wraps the revealed text in == so the TUI can highlight it.
+func extractClozes(lines []string, covered []bool, scopes []scope, sourceFile string, position int) []Card {
+	var cards []Card
+	for i := 0; i < len(lines); {
+		if covered[i] || strings.TrimSpace(lines[i]) == "" {
+			i++
+			continue
+		}
+		start := i
+		for i < len(lines) && !covered[i] && strings.TrimSpace(lines[i]) != "" {
+			i++
+		}
+
+		for _, c := range clozeCards(strings.TrimSpace(strings.Join(lines[start:i], "\n"))) {
+			c.DeckName = scopes[start].deck
+			c.Headings = scopes[start].headings
+			c.SourceFile = sourceFile
+			// the blanked and the revealed text are the same paragraph
+			c.QuestionLines = LineRange{Start: start + 1, End: i}
+			c.AnswerLines = c.QuestionLines
+			c.Position = position
+			position++
+			cards = append(cards, c)
+		}
+	}
+	return cards
+}
+
+// clozeCards builds the cards for a single paragraph.
+func clozeCards(text string) []Card {
+	id := ""
+	if m := blockIDRe.FindStringSubmatch(text); m != nil {
+		id = m[1]
+		text = strings.TrimSpace(text[:len(text)-len(m[0])])
+	}
+
+	var cards []Card
+	if hasAnkiCloze(text) {
+		var groups []string
+		seen := make(map[string]bool)
+		for _, m := range ankiClozeRe.FindAllStringSubmatch(text, -1) {
+			if !seen[m[1]] {
+				seen[m[1]] = true
+				groups = append(groups, m[1])
+			}
+		}
+		for _, g := range groups {
+			q := ankiClozeRe.ReplaceAllStringFunc(text, func(s string) string {
+				m := ankiClozeRe.FindStringSubmatch(s)
+				if m[1] != g {
+					return m[2]
+				}
+				if m[3] != "" {
+					return "[" + m[3] + "]"
+				}
+				return clozeBlank
+			})
+			a := ankiClozeRe.ReplaceAllStringFunc(text, func(s string) string {
+				m := ankiClozeRe.FindStringSubmatch(s)
+				if m[1] != g {
+					return m[2]
+				}
+				return "==" + m[2] + "=="
+			})
+			cards = append(cards, Card{Question: q, Answer: a, Cloze: "c" + g})
+		}
+	} else {
+		matches := highlightClozeRe.FindAllStringSubmatchIndex(text, -1)
+		for n := range matches {
+			var q, a strings.Builder
+			last := 0
+			for k, m := range matches {
+				q.WriteString(text[last:m[0]])
+				a.WriteString(text[last:m[0]])
+				inner := text[m[2]:m[3]]
+				if k == n {
+					q.WriteString(clozeBlank)
+					a.WriteString("==" + inner + "==")
+				} else {
+					q.WriteString(inner)
+					a.WriteString(inner)
+				}
+				last = m[1]
+			}
+			q.WriteString(text[last:])
+			a.WriteString(text[last:])
+			cards = append(cards, Card{Question: q.String(), Answer: a.String(), Cloze: "h" + strconv.Itoa(n+1)})
+		}
+	}
+
+	if id != "" {
+		for i := range cards {
+			cards[i].ID = id + "-" + cards[i].Cloze
+		}
+	}
+	return cards
+}
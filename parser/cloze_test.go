@@ -0,0 +1,113 @@
+package parser
+
+import "testing"
+
+func TestClozeCards(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		wantQ     []string
+		wantA     []string
+		wantCloze []string
+	}{
+		{
+			name:      "single highlight",
+			text:      "The capital of France is ==Paris==.",
+			wantQ:     []string{"The capital of France is [...]."},
+			wantA:     []string{"The capital of France is ==Paris==."},
+			wantCloze: []string{"h1"},
+		},
+		{
+			name:      "each highlight is its own card",
+			text:      "==Go== was created at ==Google==",
+			wantQ:     []string{"[...] was created at Google", "Go was created at [...]"},
+			wantA:     []string{"==Go== was created at Google", "Go was created at ==Google=="},
+			wantCloze: []string{"h1", "h2"},
+		},
+		{
+			name:      "anki groups by number",
+			text:      "{{c1::Paris}} is in {{c2::France}} on the {{c1::Seine}}",
+			wantQ:     []string{"[...] is in France on the [...]", "Paris is in [...] on the Seine"},
+			wantA:     []string{"==Paris== is in France on the ==Seine==", "Paris is in ==France== on the Seine"},
+			wantCloze: []string{"c1", "c2"},
+		},
+		{
+			name:      "anki hint",
+			text:      "The capital of France is {{c1::Paris::city}}",
+			wantQ:     []string{"The capital of France is [city]"},
+			wantA:     []string{"The capital of France is ==Paris=="},
+			wantCloze: []string{"c1"},
+		},
+		{
+			name: "no cloze",
+			text: "Just a paragraph",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cards := clozeCards(tt.text)
+			if len(cards) != len(tt.wantQ) {
+				t.Fatalf("got %d cards, want %d", len(cards), len(tt.wantQ))
+			}
+			for i, c := range cards {
+				if c.Question != tt.wantQ[i] {
+					t.Errorf("card %d question = %q, want %q", i, c.Question, tt.wantQ[i])
+				}
+				if c.Answer != tt.wantA[i] {
+					t.Errorf("card %d answer = %q, want %q", i, c.Answer, tt.wantA[i])
+				}
+				if c.Cloze != tt.wantCloze[i] {
+					t.Errorf("card %d cloze = %q, want %q", i, c.Cloze, tt.wantCloze[i])
+				}
+			}
+		})
+	}
+}
+
+func TestClozeStableID(t *testing.T) {
+	cards := clozeCards("{{c1::Paris}} is in {{c2::France}} ^capitals")
+	if len(cards) != 2 {
+		t.Fatalf("got %d cards, want 2", len(cards))
+	}
+	if cards[0].Key() != "^capitals-c1" || cards[1].Key() != "^capitals-c2" {
+		t.Errorf("keys = %q, %q", cards[0].Key(), cards[1].Key())
+	}
+	if cards[0].Question != "[...] is in France" {
+		t.Errorf("block ID should be stripped, question = %q", cards[0].Question)
+	}
+}
+
+func TestExtractCardsWithClozes(t *testing.T) {
+	lines := []string{
+		"#flashcards/geo",
+		"",
+		"The capital of France is ==Paris==",
+		"",
+		"What is the capital of Spain?",
+		"?",
+		"Madrid, a ==big== city",
+		"",
+		"{{c1::Berlin}} is the capital of Germany",
+	}
+
+	cards, _ := extractCards(lines, "geo", "geo.md")
+	if len(cards) != 3 {
+		for _, c := range cards {
+			t.Logf("card: %q / %q", c.Question, c.Answer)
+		}
+		t.Fatalf("got %d cards, want 3", len(cards))
+	}
+	if cards[0].Answer != "Madrid, a ==big== city" {
+		t.Errorf("regular answer = %q, highlights in answers must not become clozes", cards[0].Answer)
+	}
+	if cards[1].Question != "The capital of France is [...]" {
+		t.Errorf("cloze before cards = %q", cards[1].Question)
+	}
+	if cards[2].Question != "[...] is the capital of Germany" {
+		t.Errorf("anki cloze after answer = %q", cards[2].Question)
+	}
+	if cards[1].Position != 1 || cards[2].Position != 2 {
+		t.Errorf("positions = %d, %d, want 1, 2", cards[1].Position, cards[2].Position)
+	}
+}
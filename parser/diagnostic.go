@@ -0,0 +1,67 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Severity says how bad a Diagnostic is.
+type Severity string
+
+const (
+	// SeverityError marks a file, or part of one, that could not be parsed.
+	SeverityError Severity = "error"
+	// SeverityWarning marks card syntax that parsed but is probably a mistake.
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic is a problem found while parsing notes.
+type Diagnostic struct {
+	File     string   `json:"file"`
+	Line     int      `json:"line,omitempty"` // 1-based; 0 if it concerns the whole file
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+// String formats the diagnostic as "file:line: severity: message".
+func (d Diagnostic) String() string {
+	if d.Line == 0 {
+		return fmt.Sprintf("%s: %s: %s", d.File, d.Severity, d.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s: %s", d.File, d.Line, d.Severity, d.Message)
+}
+
+// SortDiagnostics orders diagnostics by file, then line.
+func SortDiagnostics(diags []Diagnostic) {
+	sort.SliceStable(diags, func(i, j int) bool {
+		if diags[i].File != diags[j].File {
+			return diags[i].File < diags[j].File
+		}
+		return diags[i].Line < diags[j].Line
+	})
+}
+
+// duplicateDiagnostics warns about every card whose Key was already used by
+// an earlier card: they would share one schedule.
+func duplicateDiagnostics(cards []Card) []Diagnostic {
+	var diags []Diagnostic
+	first := make(map[string]Card, len(cards))
+	for _, c := range cards {
+		prev, seen := first[c.Key()]
+		if !seen {
+			first[c.Key()] = c
+			continue
+		}
+		what := "question"
+		if c.ID != "" {
+			what = "card ID ^" + c.ID
+		}
+		diags = append(diags, Diagnostic{
+			File:     c.SourceFile,
+			Line:     c.QuestionLines.Start,
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("duplicate %s, first defined at %s", what, prev.Location()),
+		})
+	}
+	return diags
+}
@@ -0,0 +1,94 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseDirectoryDiagnostics(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a.md": "#flashcards\n\n?\nOrphan answer\n\nNo answer?\n?\n\nWhat is Go?\n?\nA language\n",
+		"b.md": "#flashcards\n\nWhat is Go?\n?\nStill a language\n\nQ\n? ^id\nA\n",
+		"c.md": "#flashcards\n\nOther\n? ^id\nA\n",
+		"d.md": "---\ntags: [unclosed\n---\n#flashcards\n",
+		"e.md": "#flashcards\n\nLong?\n?\n" + strings.Repeat("x", 100_000) + "\n",
+	})
+	if err := os.Symlink(filepath.Join(dir, "missing.md"), filepath.Join(dir, "f.md")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	cards, diags, err := ParseDirectoryWith(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		file     string
+		line     int
+		severity Severity
+		message  string
+	}{
+		{"a.md", 3, SeverityWarning, "no question"},
+		{"a.md", 7, SeverityWarning, "no answer"},
+		{"b.md", 3, SeverityWarning, "duplicate question, first defined at " + filepath.Join(dir, "a.md") + ":9"},
+		{"c.md", 3, SeverityWarning, "duplicate card ID ^id"},
+		{"d.md", 1, SeverityError, "frontmatter"},
+		{"f.md", 0, SeverityError, "no such file"},
+	}
+	if len(diags) != len(want) {
+		t.Fatalf("got %d diagnostics, want %d:\n%v", len(diags), len(want), diags)
+	}
+	for i, w := range want {
+		d := diags[i]
+		if d.File != filepath.Join(dir, w.file) || d.Line != w.line || d.Severity != w.severity || !strings.Contains(d.Message, w.message) {
+			t.Errorf("diag[%d] = %v, want %s:%d %s containing %q", i, d, w.file, w.line, w.severity, w.message)
+		}
+	}
+
+	var long bool
+	for _, c := range cards {
+		if c.Question == "Long?" && len(c.Answer) == 100_000 {
+			long = true
+		}
+	}
+	if !long {
+		t.Error("a file with a line over 64KB was not parsed")
+	}
+}
+
+func TestDiagnosticString(t *testing.T) {
+	tests := []struct {
+		d    Diagnostic
+		want string
+	}{
+		{Diagnostic{File: "go.md", Line: 4, Severity: SeverityWarning, Message: "card has no answer"}, "go.md:4: warning: card has no answer"},
+		{Diagnostic{File: "go.md", Severity: SeverityError, Message: "permission denied"}, "go.md: error: permission denied"},
+	}
+	for _, tt := range tests {
+		if got := tt.d.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"", nil},
+		{"a", []string{"a"}},
+		{"a\n", []string{"a"}},
+		{"a\r\nb\r\n", []string{"a", "b"}},
+		{"a\n\nb", []string{"a", "", "b"}},
+	}
+	for _, tt := range tests {
+		got := splitLines(tt.text)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+			t.Errorf("splitLines(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
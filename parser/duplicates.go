@@ -0,0 +1,94 @@
+package parser
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// disambiguate gives every card whose question another card also uses a
+// Qualifier, so each gets its own Key and schedule: its deck if no other
+// card in the group shares it, else its file relative to root, numbered if
+// the file repeats the question. Cards with stable IDs are left alone; a
+// repeated ID is the author's to fix. Qualifiers from an earlier call are
+// worked out afresh.
+func disambiguate(cards []Card, root string) {
+	changed := false
+	groups := make(map[string][]int)
+	for i, c := range cards {
+		if c.Qualifier != "" {
+			cards[i].Qualifier = ""
+			changed = true
+		}
+		if c.ID == "" {
+			groups[c.Question] = append(groups[c.Question], i)
+		}
+	}
+
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+		decks := make(map[string]int)
+		files := make(map[string]int)
+		for _, i := range group {
+			decks[cards[i].DeckName]++
+			files[cards[i].SourceFile]++
+		}
+
+		seen := make(map[string]int)
+		for _, i := range group {
+			c := &cards[i]
+			file := filepath.ToSlash(c.SourceFile)
+			if rel, err := filepath.Rel(root, c.SourceFile); err == nil {
+				file = filepath.ToSlash(rel)
+			}
+			switch {
+			case decks[c.DeckName] == 1:
+				c.Qualifier = "deck:" + c.DeckName
+			case files[c.SourceFile] == 1:
+				c.Qualifier = "file:" + file
+			default:
+				seen[c.SourceFile]++
+				c.Qualifier = fmt.Sprintf("file:%s#%d", file, seen[c.SourceFile])
+			}
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+
+	// Keys changed, so point reversible pairs at each other again. The
+	// halves are always adjacent, forward first.
+	for i := range cards {
+		switch {
+		case cards[i].Sibling == "":
+		case cards[i].Reverse && i > 0:
+			cards[i].Sibling = cards[i-1].Key()
+		case !cards[i].Reverse && i+1 < len(cards):
+			cards[i].Sibling = cards[i+1].Key()
+		}
+	}
+}
+
+// Duplicates groups the cards that share a question, in the order their
+// first card appears. Each group was disambiguated by ParseDirectoryWith.
+func Duplicates(cards []Card) [][]Card {
+	var order []string
+	groups := make(map[string][]Card)
+	for _, c := range cards {
+		if c.Qualifier == "" {
+			continue
+		}
+		if _, ok := groups[c.Question]; !ok {
+			order = append(order, c.Question)
+		}
+		groups[c.Question] = append(groups[c.Question], c)
+	}
+
+	dups := make([][]Card, len(order))
+	for i, q := range order {
+		dups[i] = groups[q]
+	}
+	return dups
+}
@@ -0,0 +1,75 @@
+package parser
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestDisambiguate(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"go.md":         "#flashcards/go\n\nWhat is a map?\n?\nA hash table.\n\nWhat is Go?\n?\nA language.\n",
+		"rust.md":       "#flashcards/rust\n\nWhat is a map?\n?\nHashMap.\n",
+		"notes/a.md":    "#flashcards/misc\n\nWhat is a map?\n??\nmap\n",
+		"notes/b.md":    "#flashcards/misc\n\nWhat is a map?\n?\nB.\n\nWhat is a map?\n?\nB again.\n",
+		"ids/one.md":    "#flashcards/ids\n\nSame ID\n? ^dup\nA\n",
+		"ids/two.md":    "#flashcards/ids\n\nSame ID\n? ^dup\nB\n",
+		"unique/one.md": "#flashcards/unique\n\nOnly here\n?\nA\n",
+	})
+
+	cards, _, err := ParseDirectoryWith(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{ // file/answer → qualifier
+		"go.md/A hash table.":       "deck:go",
+		"go.md/A language.":         "",
+		"rust.md/HashMap.":          "deck:rust",
+		"notes/a.md/map":            "file:notes/a.md",
+		"notes/a.md/What is a map?": "",
+		"notes/b.md/B.":             "file:notes/b.md#1",
+		"notes/b.md/B again.":       "file:notes/b.md#2",
+		"ids/one.md/A":              "",
+		"ids/two.md/B":              "",
+		"unique/one.md/A":           "",
+	}
+	keys := make(map[string]bool)
+	for _, c := range cards {
+		rel, _ := filepath.Rel(dir, c.SourceFile)
+		id := filepath.ToSlash(rel) + "/" + c.Answer
+		q, ok := want[id]
+		if !ok {
+			t.Errorf("unexpected card %s", id)
+			continue
+		}
+		if c.Qualifier != q {
+			t.Errorf("%s: qualifier = %q, want %q", id, c.Qualifier, q)
+		}
+		if c.Qualifier != "" {
+			if keys[c.Key()] {
+				t.Errorf("%s: key %q is not unique", id, c.Key())
+			}
+			keys[c.Key()] = true
+		}
+	}
+
+	// The reversible pair still points at each other.
+	for i, c := range cards {
+		if c.Sibling == "" {
+			continue
+		}
+		other := cards[i+1]
+		if c.Reverse {
+			other = cards[i-1]
+		}
+		if c.Sibling != other.Key() {
+			t.Errorf("%q: sibling = %q, want %q", c.Question, c.Sibling, other.Key())
+		}
+	}
+
+	dups := Duplicates(cards)
+	if len(dups) != 1 || len(dups[0]) != 5 || dups[0][0].Question != "What is a map?" {
+		t.Errorf("Duplicates() = %d groups, want one group of 5", len(dups))
+	}
+}
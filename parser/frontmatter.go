@@ -0,0 +1,94 @@
+package parser
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Meta is the metadata from a file's YAML frontmatter, shared by every card
+// in the file.
+type Meta struct {
+	Deck    string   // explicit deck; wins over any #flashcards tag
+	Tags    []string // frontmatter tags, without a leading #
+	Suspend bool     // cards are parsed but left out of reviews
+
+	// Per-file scheduling overrides; zero values mean "use the config".
+	Scheduler        string
+	DesiredRetention float64
+
+	// Fields holds every frontmatter key, including ones not modelled above.
+	Fields map[string]any
+}
+
+// frontmatter is the YAML shape of Meta. Tags may be a list or a single
+// comma- or space-separated string, as Obsidian accepts both.
+type frontmatter struct {
+	Deck             string    `yaml:"deck"`
+	Tags             yaml.Node `yaml:"tags"`
+	Suspend          bool      `yaml:"suspend"`
+	Scheduler        string    `yaml:"scheduler"`
+	DesiredRetention float64   `yaml:"desired_retention"`
+}
+
+// parseFrontmatter reads a leading "---" YAML block. It returns the metadata
+// and the line count of the block including both delimiters, or 0 if the
+// file has none. Malformed YAML is reported as an error.
+func parseFrontmatter(lines []string) (Meta, int, error) {
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return Meta{}, 0, nil
+	}
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		if t := strings.TrimSpace(lines[i]); t == "---" || t == "..." {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return Meta{}, 0, nil
+	}
+
+	src := []byte(strings.Join(lines[1:end], "\n"))
+	var fm frontmatter
+	if err := yaml.Unmarshal(src, &fm); err != nil {
+		return Meta{}, end + 1, err
+	}
+	var fields map[string]any
+	if err := yaml.Unmarshal(src, &fields); err != nil {
+		return Meta{}, end + 1, err
+	}
+
+	meta := Meta{
+		Deck:             fm.Deck,
+		Suspend:          fm.Suspend,
+		Scheduler:        fm.Scheduler,
+		DesiredRetention: fm.DesiredRetention,
+		Fields:           fields,
+	}
+	switch fm.Tags.Kind {
+	case yaml.SequenceNode:
+		for _, n := range fm.Tags.Content {
+			meta.Tags = append(meta.Tags, strings.TrimPrefix(n.Value, "#"))
+		}
+	case yaml.ScalarNode:
+		for _, t := range strings.FieldsFunc(fm.Tags.Value, func(r rune) bool { return r == ',' || r == ' ' }) {
+			meta.Tags = append(meta.Tags, strings.TrimPrefix(t, "#"))
+		}
+	}
+	return meta, end + 1, nil
+}
+
+// deck returns the deck the frontmatter assigns: the deck key, or else the
+// first flashcards tag. Empty if the frontmatter doesn't make the file a deck.
+func (m Meta) deck() string {
+	if m.Deck != "" {
+		return strings.ReplaceAll(m.Deck, "/", ".")
+	}
+	for _, tag := range m.Tags {
+		if deck, ok := deckFromTag("#" + tag); ok {
+			return deck
+		}
+	}
+	return ""
+}
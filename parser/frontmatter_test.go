@@ -0,0 +1,127 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseFrontmatter(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []string
+		wantN    int
+		wantDeck string
+		wantTags []string
+		wantErr  bool
+	}{
+		{
+			name:  "no frontmatter",
+			lines: []string{"#flashcards", "Q", "?", "A"},
+			wantN: 0,
+		},
+		{
+			name:     "tag list",
+			lines:    []string{"---", "tags: [flashcards/go, notes]", "---", "Q"},
+			wantN:    3,
+			wantDeck: "go",
+			wantTags: []string{"flashcards/go", "notes"},
+		},
+		{
+			name:     "tag string with hashes",
+			lines:    []string{"---", "tags: '#flashcards/cs/algo, #todo'", "..."},
+			wantN:    3,
+			wantDeck: "cs.algo",
+			wantTags: []string{"flashcards/cs/algo", "todo"},
+		},
+		{
+			name:     "deck wins over tags",
+			lines:    []string{"---", "deck: lang/rust", "tags:", "  - flashcards/go", "---"},
+			wantN:    5,
+			wantDeck: "lang.rust",
+			wantTags: []string{"flashcards/go"},
+		},
+		{
+			name:  "unterminated block is body text",
+			lines: []string{"---", "deck: go"},
+			wantN: 0,
+		},
+		{
+			name:    "malformed yaml",
+			lines:   []string{"---", "tags: [unclosed", "---"},
+			wantN:   3,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta, n, err := parseFrontmatter(tt.lines)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if n != tt.wantN {
+				t.Errorf("n = %d, want %d", n, tt.wantN)
+			}
+			if got := meta.deck(); got != tt.wantDeck {
+				t.Errorf("deck = %q, want %q", got, tt.wantDeck)
+			}
+			if !reflect.DeepEqual(meta.Tags, tt.wantTags) {
+				t.Errorf("tags = %q, want %q", meta.Tags, tt.wantTags)
+			}
+		})
+	}
+}
+
+func TestParseFileBrokenFrontmatter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "go.md")
+	content := "---\ndeck: go\ntags: [unclosed\n---\n#flashcards/misc\n\nWhat is a goroutine?\n?\nA lightweight thread.\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cards, diags, err := parseFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) != 1 || diags[0].Line != 1 || diags[0].Severity != SeverityError {
+		t.Errorf("diagnostics = %v, want one frontmatter error at line 1", diags)
+	}
+	if len(cards) != 1 {
+		t.Fatalf("got %d cards, want 1", len(cards))
+	}
+	if c := cards[0]; c.DeckName != "misc" || c.Question != "What is a goroutine?" || c.QuestionLines.Start != 7 {
+		t.Errorf("card = %q/%q at line %d, want misc/What is a goroutine? at 7", c.DeckName, c.Question, c.QuestionLines.Start)
+	}
+}
+
+func TestParseFileFrontmatter(t *testing.T) {
+	dir := t.TempDir()
+	content := "---\ndeck: go\nsuspend: true\nscheduler: fsrs\ndesired_retention: 0.85\nsource: book\n---\nWhat is a goroutine?\n?\nA lightweight thread.\n"
+	path := filepath.Join(dir, "go.md")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cards, _, err := parseFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cards) != 1 {
+		t.Fatalf("got %d cards, want 1", len(cards))
+	}
+	c := cards[0]
+	if c.DeckName != "go" || c.Question != "What is a goroutine?" {
+		t.Errorf("card = %q/%q, want go/What is a goroutine?", c.DeckName, c.Question)
+	}
+	if c.Position != 0 {
+		t.Errorf("position = %d, want 0", c.Position)
+	}
+	if !c.Meta.Suspend || c.Meta.Scheduler != "fsrs" || c.Meta.DesiredRetention != 0.85 {
+		t.Errorf("meta = %+v, want suspended fsrs at 0.85", c.Meta)
+	}
+	if c.Meta.Fields["source"] != "book" {
+		t.Errorf("fields[source] = %v, want book", c.Meta.Fields["source"])
+	}
+}
@@ -0,0 +1,103 @@
+package parser
+
+import (
+	"slices"
+	"strings"
+)
+
+// scope is what applies to a line from the headings above it: the deck its
+// cards belong to and the path of enclosing headings, outermost first.
+type scope struct {
+	deck     string
+	headings []string
+}
+
+// sectionScopes returns the scope of every line. A #flashcards tag on a
+// heading, or in the text under it, sets the deck for that section until the
+// next heading of the same or a higher level; nested sections inherit it.
+// Lines outside any tagged section use fileDeck.
+func sectionScopes(lines []string, verbatim []bool, fileDeck string) []scope {
+	type section struct {
+		level  int
+		text   string
+		deck   string
+		parent int // enclosing section, -1 at the top level
+	}
+
+	var sections []section
+	in := make([]int, len(lines)) // innermost section of each line, -1 before the first heading
+	cur := -1
+	for i, line := range lines {
+		if !verbatim[i] {
+			if level, text, ok := parseHeading(line); ok {
+				parent := cur
+				for parent >= 0 && sections[parent].level >= level {
+					parent = sections[parent].parent
+				}
+				sections = append(sections, section{level: level, text: text, parent: parent})
+				cur = len(sections) - 1
+			}
+			if cur >= 0 && sections[cur].deck == "" {
+				for _, word := range strings.Fields(line) {
+					if deck, ok := deckFromTag(word); ok {
+						sections[cur].deck = deck
+						break
+					}
+				}
+			}
+		}
+		in[i] = cur
+	}
+
+	// Parents always come before their children, so one pass resolves them.
+	resolved := make([]scope, len(sections))
+	for j, s := range sections {
+		parent := scope{deck: fileDeck}
+		if s.parent >= 0 {
+			parent = resolved[s.parent]
+		}
+		deck := parent.deck
+		if s.deck != "" {
+			deck = s.deck
+		}
+		resolved[j] = scope{deck: deck, headings: append(slices.Clip(parent.headings), s.text)}
+	}
+
+	scopes := make([]scope, len(lines))
+	for i, j := range in {
+		if j < 0 {
+			scopes[i] = scope{deck: fileDeck}
+		} else {
+			scopes[i] = resolved[j]
+		}
+	}
+	return scopes
+}
+
+// parseHeading reports whether line is an ATX heading ("## Title") and
+// returns its level and text, without #flashcards tags or closing hashes.
+func parseHeading(line string) (level int, text string, ok bool) {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return 0, "", false
+	}
+	level = len(trimmed) - len(strings.TrimLeft(trimmed, "#"))
+	if level == 0 || level > 6 {
+		return 0, "", false
+	}
+	rest := trimmed[level:]
+	if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+		return 0, "", false // a tag like #flashcards, not a heading
+	}
+
+	var words []string
+	for _, word := range strings.Fields(rest) {
+		if !containsFlashcardsTag(word) {
+			words = append(words, word)
+		}
+	}
+	if n := len(words); n > 0 && strings.Trim(words[n-1], "#") == "" {
+		words = words[:n-1] // closing sequence, as in "## Title ##"
+	}
+	return level, strings.Join(words, " "), true
+}
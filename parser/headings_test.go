@@ -0,0 +1,184 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseHeading(t *testing.T) {
+	tests := []struct {
+		line      string
+		wantLevel int
+		wantText  string
+		wantOK    bool
+	}{
+		{line: "# Title", wantLevel: 1, wantText: "Title", wantOK: true},
+		{line: "### Deep   heading", wantLevel: 3, wantText: "Deep heading", wantOK: true},
+		{line: "## Concurrency #flashcards/go/concurrency", wantLevel: 2, wantText: "Concurrency", wantOK: true},
+		{line: "## Closed ##", wantLevel: 2, wantText: "Closed", wantOK: true},
+		{line: "#flashcards/go", wantOK: false},
+		{line: "####### seven", wantOK: false},
+		{line: "    # indented", wantOK: false},
+		{line: "plain text", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			level, text, ok := parseHeading(tt.line)
+			if ok != tt.wantOK || level != tt.wantLevel || text != tt.wantText {
+				t.Errorf("parseHeading(%q) = %d, %q, %v; want %d, %q, %v",
+					tt.line, level, text, ok, tt.wantLevel, tt.wantText, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestExtractCardsHeadingScopes(t *testing.T) {
+	lines := []string{
+		"# Go",                           // 0
+		"",                               // 1
+		"What is Go?",                    // 2
+		"?",                              // 3
+		"A language.",                    // 4
+		"",                               // 5
+		"## Concurrency",                 // 6
+		"#flashcards/go/concurrency",     // 7
+		"",                               // 8
+		"What is a goroutine?",           // 9
+		"?",                              // 10
+		"A lightweight thread.",          // 11
+		"",                               // 12
+		"### Channels",                   // 13
+		"",                               // 14
+		"chan::typed pipe",               // 15
+		"",                               // 16
+		"## Generics #flashcards/go/gen", // 17
+		"",                               // 18
+		"Go has ==type parameters==.",    // 19
+		"",                               // 20
+		"## Tooling",                     // 21
+		"",                               // 22
+		"What formats code?",             // 23
+		"?",                              // 24
+		"gofmt",                          // 25
+	}
+
+	cards, _ := extractCards(lines, "go", "go.md")
+
+	want := []struct {
+		question string
+		deck     string
+		headings []string
+	}{
+		{"What is Go?", "go", []string{"Go"}},
+		{"What is a goroutine?", "go.concurrency", []string{"Go", "Concurrency"}},
+		{"What formats code?", "go", []string{"Go", "Tooling"}},
+		{"chan", "go.concurrency", []string{"Go", "Concurrency", "Channels"}},
+		{"Go has [...].", "go.gen", []string{"Go", "Generics"}},
+	}
+	if len(cards) != len(want) {
+		t.Fatalf("got %d cards, want %d: %+v", len(cards), len(want), cards)
+	}
+	for i, w := range want {
+		c := cards[i]
+		if c.Question != w.question || c.DeckName != w.deck || !reflect.DeepEqual(c.Headings, w.headings) {
+			t.Errorf("card[%d] = %q in %q under %q, want %q in %q under %q",
+				i, c.Question, c.DeckName, c.Headings, w.question, w.deck, w.headings)
+		}
+	}
+}
+
+func TestParseFileDeckTagAnywhere(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string // question -> deck
+	}{
+		{
+			name: "tag at the bottom",
+			content: "# Go notes\n\n## Basics\n\nWhat is Go?\n?\nA language.\n\n" +
+				"What formats code?\n?\ngofmt\n\n#flashcards/go\n",
+			want: map[string]string{"What is Go?": "go", "What formats code?": "go"},
+		},
+		{
+			name: "tag under an earlier heading",
+			content: "## Intro #flashcards/rust\n\nWhat is Rust?\n?\nA language.\n\n" +
+				"## Ownership\n\nWho frees memory?\n?\nThe owner.\n\n" +
+				"## Async #flashcards/rust/async\n\nWhat runs futures?\n?\nAn executor.\n",
+			want: map[string]string{"What is Rust?": "rust", "Who frees memory?": "rust", "What runs futures?": "rust.async"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "notes.md")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			cards, diags, err := parseFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(diags) != 0 {
+				t.Errorf("unexpected diagnostics: %v", diags)
+			}
+			got := make(map[string]string)
+			for _, c := range cards {
+				got[c.Question] = c.DeckName
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decks = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractCardsAnswerStopsAtHeading(t *testing.T) {
+	lines := []string{
+		"#flashcards/go",
+		"",
+		"What is Go?",
+		"?",
+		"A language.",
+		"",
+		"## Concurrency",
+		"#flashcards/go/concurrency",
+		"Some notes.",
+		"",
+		"What is a goroutine?",
+		"?",
+		"A lightweight thread.",
+		"",
+		"## Tooling",
+		"More notes.",
+	}
+
+	cards, _ := extractCards(lines, "go", "go.md")
+	if len(cards) != 2 {
+		t.Fatalf("got %d cards, want 2", len(cards))
+	}
+	if cards[0].Answer != "A language." || cards[0].AnswerLines != (LineRange{5, 5}) {
+		t.Errorf("first answer = %q at %v, want it to stop before the heading", cards[0].Answer, cards[0].AnswerLines)
+	}
+	if cards[1].Answer != "A lightweight thread." {
+		t.Errorf("last answer = %q, want it to stop before the heading", cards[1].Answer)
+	}
+}
+
+func TestSectionScopesPreamble(t *testing.T) {
+	lines := []string{"intro", "```", "# not a heading", "```", "## Real"}
+	scopes := sectionScopes(lines, verbatimLines(lines), "notes")
+
+	if scopes[0].deck != "notes" || scopes[0].headings != nil {
+		t.Errorf("preamble scope = %+v, want file deck and no headings", scopes[0])
+	}
+	if !reflect.DeepEqual(scopes[2].headings, []string(nil)) {
+		t.Errorf("heading inside a code block opened a section: %+v", scopes[2])
+	}
+	if !reflect.DeepEqual(scopes[4].headings, []string{"Real"}) {
+		t.Errorf("scope = %+v, want headings [Real]", scopes[4])
+	}
+}
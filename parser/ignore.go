@@ -0,0 +1,148 @@
+package parser
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreFiles are read from every directory walked. Their patterns apply to
+// that directory and everything below it.
+var ignoreFiles = []string{".gitignore", ".ignore"}
+
+// pattern is one gitignore-style pattern.
+type pattern struct {
+	re      *regexp.Regexp // matches a slash-separated path relative to the pattern's base
+	negate  bool           // "!pattern" re-includes what earlier patterns excluded
+	dirOnly bool           // "pattern/" only matches directories
+}
+
+// compilePattern parses a line of a .gitignore file, or an include/exclude
+// glob from the config; both use the same syntax:
+//   - a pattern without a slash matches a name at any depth, one with a
+//     slash matches from the base directory;
+//   - "*" and "?" don't match "/", "**" matches any number of directories;
+//   - a trailing "/" matches directories only, a leading "!" negates.
+//
+// Blank lines, comments and malformed globs give ok == false.
+func compilePattern(line string) (p pattern, ok bool) {
+	line = strings.TrimRight(line, " \t")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return pattern{}, false
+	}
+	if rest, found := strings.CutPrefix(line, "!"); found {
+		p.negate = true
+		line = rest
+	}
+	line = strings.TrimPrefix(line, `\`) // "\#" and "\!" start with a literal
+	if rest, found := strings.CutSuffix(line, "/"); found {
+		p.dirOnly = true
+		line = rest
+	}
+	if line == "" {
+		return pattern{}, false
+	}
+
+	prefix := "^(?:.*/)?"
+	if strings.Contains(line, "/") {
+		prefix = "^"
+		line = strings.TrimPrefix(line, "/")
+	}
+	re, err := regexp.Compile(prefix + globToRegexp(line) + "$")
+	if err != nil {
+		return pattern{}, false
+	}
+	p.re = re
+	return p, true
+}
+
+// globToRegexp translates a glob into regexp syntax.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if rest, found := strings.CutPrefix(class, "!"); found {
+				class = "^" + rest
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// match reports whether the pattern matches rel, a slash-separated path
+// relative to the pattern's base directory.
+func (p pattern) match(rel string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	return p.re.MatchString(rel)
+}
+
+// compilePatterns compiles globs, dropping blank and malformed ones.
+func compilePatterns(globs []string) []pattern {
+	var patterns []pattern
+	for _, g := range globs {
+		if p, ok := compilePattern(g); ok {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// readIgnoreFiles returns the patterns from the ignore files in dir.
+func readIgnoreFiles(dir string) []pattern {
+	var patterns []pattern
+	for _, name := range ignoreFiles {
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if p, ok := compilePattern(scanner.Text()); ok {
+				patterns = append(patterns, p)
+			}
+		}
+		_ = f.Close()
+	}
+	return patterns
+}
+
+// matchPatterns applies patterns in order, the last match winning, and
+// reports whether rel ends up matched. Patterns with nothing to match
+// leave matched unchanged.
+func matchPatterns(patterns []pattern, rel string, isDir bool, matched bool) bool {
+	for _, p := range patterns {
+		if p.match(rel, isDir) {
+			matched = !p.negate
+		}
+	}
+	return matched
+}
@@ -0,0 +1,62 @@
+package parser
+
+import "testing"
+
+func TestCompilePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		isDir   bool
+		want    bool
+	}{
+		{pattern: "node_modules", path: "node_modules", isDir: true, want: true},
+		{pattern: "node_modules", path: "web/node_modules", isDir: true, want: true},
+		{pattern: "*.md", path: "deep/dir/note.md", want: true},
+		{pattern: "*.md", path: "note.txt", want: false},
+		{pattern: "templates/", path: "templates", isDir: true, want: true},
+		{pattern: "templates/", path: "templates", isDir: false, want: false},
+		{pattern: "/archive", path: "archive", isDir: true, want: true},
+		{pattern: "/archive", path: "old/archive", isDir: true, want: false},
+		{pattern: "docs/*.md", path: "docs/a.md", want: true},
+		{pattern: "docs/*.md", path: "docs/sub/a.md", want: false},
+		{pattern: "docs/**/*.md", path: "docs/a.md", want: true},
+		{pattern: "docs/**/*.md", path: "docs/x/y/a.md", want: true},
+		{pattern: "**/draft-*", path: "a/b/draft-1.md", want: true},
+		{pattern: "drafts/**", path: "drafts/a/b.md", want: true},
+		{pattern: "note?.md", path: "note1.md", want: true},
+		{pattern: "note[0-9].md", path: "note7.md", want: true},
+		{pattern: "note[!0-9].md", path: "note7.md", want: false},
+		{pattern: `\#hash.md`, path: "#hash.md", want: true},
+		{pattern: "a.md", path: "xa.md", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			p, ok := compilePattern(tt.pattern)
+			if !ok {
+				t.Fatalf("compilePattern(%q) failed", tt.pattern)
+			}
+			if got := p.match(tt.path, tt.isDir); got != tt.want {
+				t.Errorf("match(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompilePatternSkips(t *testing.T) {
+	for _, line := range []string{"", "   ", "# comment", "!", "/"} {
+		if _, ok := compilePattern(line); ok {
+			t.Errorf("compilePattern(%q) should be skipped", line)
+		}
+	}
+}
+
+func TestMatchPatternsNegation(t *testing.T) {
+	patterns := compilePatterns([]string{"*.md", "!keep.md"})
+	if !matchPatterns(patterns, "drop.md", false, false) {
+		t.Error("drop.md should match")
+	}
+	if matchPatterns(patterns, "keep.md", false, false) {
+		t.Error("keep.md should be re-included")
+	}
+}
@@ -0,0 +1,111 @@
+package parser
+
+import "strings"
+
+// parseInline splits a one-line card: "term::definition", or
+// "term:::definition" for a reversible pair. Separators inside `code` spans
+// and Anki cloze markup don't count, and both sides must be non-empty.
+//
+// So that prose isn't mistaken for cards, the separator is either spaced
+// on both sides ("capital of France :: Paris") or on neither
+// ("capital of France::Paris"). A separator spaced on one side only is an
+// Obsidian Dataview field ("status:: done"). An unspaced "::" is code when
+// the line is a single token ("std::vector", "fe80::1") or when words
+// follow it on both sides ("Use std::vector for arrays.").
+func parseInline(line string) (question, answer string, reverse bool, ok bool) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || hasAnkiCloze(trimmed) {
+		return "", "", false, false
+	}
+
+	inCode := false
+	for i := 0; i < len(trimmed); i++ {
+		switch {
+		case trimmed[i] == '`':
+			inCode = !inCode
+		case !inCode && strings.HasPrefix(trimmed[i:], "::"):
+			sepLen := 2
+			if strings.HasPrefix(trimmed[i:], ":::") {
+				sepLen = 3
+				reverse = true
+			}
+			before, after := trimmed[:i], trimmed[i+sepLen:]
+			spacedBefore := strings.TrimRight(before, " \t") != before
+			spacedAfter := strings.TrimLeft(after, " \t") != after
+			question = strings.TrimSpace(before)
+			answer = strings.TrimSpace(after)
+			switch {
+			case question == "" || answer == "" || strings.HasPrefix(answer, ":"):
+				return "", "", false, false
+			case spacedBefore != spacedAfter:
+				return "", "", false, false // Dataview field
+			case spacedBefore:
+			case !strings.ContainsAny(trimmed, " \t") && !reverse:
+				return "", "", false, false // a code token
+			case strings.ContainsAny(question, " \t") && strings.ContainsAny(answer, " \t"):
+				return "", "", false, false // code inside a sentence
+			}
+			return question, answer, reverse, true
+		}
+	}
+	return "", "", false, false
+}
+
+// startsOwnCards reports whether a paragraph holds cards of its own, so an
+// answer running into it should stop before it.
+func startsOwnCards(paragraph string) bool {
+	if hasAnkiCloze(paragraph) {
+		return true
+	}
+	for _, line := range strings.Split(paragraph, "\n") {
+		if _, _, _, ok := parseInline(line); !ok {
+			return false
+		}
+	}
+	return paragraph != ""
+}
+
+// extractInline turns every uncovered "term::definition" line into a card
+// (two for ":::") and marks it covered so it is not read as a cloze.
+func extractInline(lines []string, covered []bool, scopes []scope, sourceFile string, position int) []Card {
+	var cards []Card
+	for i, line := range lines {
+		if covered[i] {
+			continue
+		}
+		text := strings.TrimSpace(line)
+		id := ""
+		if m := blockIDRe.FindStringSubmatch(text); m != nil {
+			id = m[1]
+			text = strings.TrimSpace(text[:len(text)-len(m[0])])
+		}
+		text, typed := cutTypeAnswerTag(text)
+		q, a, reverse, ok := parseInline(text)
+		if !ok {
+			continue
+		}
+		covered[i] = true
+
+		forward := Card{
+			DeckName:      scopes[i].deck,
+			Question:      q,
+			Answer:        a,
+			SourceFile:    sourceFile,
+			QuestionLines: LineRange{Start: i + 1, End: i + 1},
+			AnswerLines:   LineRange{Start: i + 1, End: i + 1},
+			ID:            id,
+			Position:      position,
+			Headings:      scopes[i].headings,
+			TypeAnswer:    typed,
+		}
+		position++
+		if !reverse {
+			cards = append(cards, forward)
+			continue
+		}
+
+		position++
+		cards = append(cards, reversePair(forward)...)
+	}
+	return cards
+}
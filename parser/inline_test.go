@@ -0,0 +1,93 @@
+package parser
+
+import "testing"
+
+func TestParseInline(t *testing.T) {
+	tests := []struct {
+		line        string
+		wantQ       string
+		wantA       string
+		wantReverse bool
+		wantOK      bool
+	}{
+		{"goroutine::a lightweight thread", "goroutine", "a lightweight thread", false, true},
+		{"  term :: definition  ", "term", "definition", false, true},
+		{"Hund:::dog", "Hund", "dog", true, true},
+		{"::missing question", "", "", false, false},
+		{"missing answer::", "", "", false, false},
+		{"too many::::colons", "", "", false, false},
+		{"use `std::move` here", "", "", false, false},
+		{"{{c1::Paris}} is a city", "", "", false, false},
+		{"no separator: here", "", "", false, false},
+		{"capital of France :: Paris", "capital of France", "Paris", false, true},
+		{"status:: done", "", "", false, false},
+		{"status ::done", "", "", false, false},
+		{"Use std::vector for arrays.", "", "", false, false},
+		{"What is the capital of France::Paris", "What is the capital of France", "Paris", false, true},
+		{"std::vector", "", "", false, false},
+		{"fe80::1", "", "", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			q, a, reverse, ok := parseInline(tt.line)
+			if ok != tt.wantOK || q != tt.wantQ || a != tt.wantA || reverse != tt.wantReverse {
+				t.Errorf("parseInline(%q) = (%q, %q, %v, %v), want (%q, %q, %v, %v)",
+					tt.line, q, a, reverse, ok, tt.wantQ, tt.wantA, tt.wantReverse, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestExtractCardsInlineSkipsProse(t *testing.T) {
+	lines := []string{
+		"#flashcards/cpp",
+		"status:: done",
+		"",
+		"Use std::vector for arrays.",
+		"",
+		"vector::a growable array",
+		"",
+		"std::vector",
+		"",
+		"fe80::1",
+	}
+
+	cards, _ := extractCards(lines, "cpp", "cpp.md")
+	if len(cards) != 1 || cards[0].Question != "vector" {
+		t.Errorf("cards = %+v, want only the vector card", cards)
+	}
+}
+
+func TestExtractCardsInline(t *testing.T) {
+	lines := []string{
+		"#flashcards/go",
+		"chan::a typed conduit",
+		"",
+		"What does defer do?",
+		"?",
+		"Runs a call when the function returns",
+		"",
+		"go:::starts a goroutine ^go-kw",
+	}
+
+	cards, _ := extractCards(lines, "go", "go.md")
+	if len(cards) != 4 {
+		for _, c := range cards {
+			t.Logf("card: %q / %q", c.Question, c.Answer)
+		}
+		t.Fatalf("got %d cards, want 4", len(cards))
+	}
+	if cards[0].Answer != "Runs a call when the function returns" {
+		t.Errorf("answer = %q, inline card should not be part of it", cards[0].Answer)
+	}
+	if cards[1].Question != "chan" || cards[1].Answer != "a typed conduit" || cards[1].DeckName != "go" {
+		t.Errorf("inline card = %+v", cards[1])
+	}
+	if cards[2].Key() != "^go-kw" || cards[3].Key() != "^go-kw-reverse" || !cards[3].Reverse {
+		t.Errorf("reversible inline keys = %q, %q", cards[2].Key(), cards[3].Key())
+	}
+	if cards[3].Question != "starts a goroutine" {
+		t.Errorf("reverse question = %q", cards[3].Question)
+	}
+}
@@ -1,86 +1,274 @@
 package parser
 
 import (
-	"bufio"
+	"fmt"
 	"os"
-	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
+	"sync"
 )
 
 type Card struct {
-	DeckName   string
-	Question   string
-	Answer     string
-	SourceFile string
+	DeckName      string
+	Question      string
+	Answer        string
+	SourceFile    string
+	QuestionLines LineRange // where the question text sits in SourceFile
+	AnswerLines   LineRange // where the answer text sits; zero if there is none
+	ID            string    // optional stable ID from a "? ^id" separator or "^id" after a cloze
+	Position      int       // index of the card within its source file
+	Cloze         string    // cloze group ("c1", "h2") for cards generated from cloze deletions
+	Reverse       bool      // answer-to-question half of a "??" or ":::" pair
+	Sibling       string    // Key of the other half of a reversible pair
+	Meta          Meta      // frontmatter of the source file
+	Headings      []string  // enclosing headings, outermost first
+	Qualifier     string    // "deck:…" or "file:…" when another card has the same question
+	TypeAnswer    bool      // the question is tagged #type-answer: the answer is typed in during review
 }
 
-func ParseDirectory(root string) ([]Card, error) {
-	var cards []Card
+// Location returns "file:line" for the start of the question.
+func (c Card) Location() string {
+	return fmt.Sprintf("%s:%d", c.SourceFile, c.QuestionLines.Start)
+}
+
+// LineRange is a span of lines in a source file, 1-based and inclusive.
+type LineRange struct {
+	Start, End int
+}
+
+// textRange returns the lines in [start, end) (0-based) that hold text,
+// skipping blank lines at either end.
+func textRange(lines []string, start, end int) LineRange {
+	for start < end && strings.TrimSpace(lines[start]) == "" {
+		start++
+	}
+	for end > start && strings.TrimSpace(lines[end-1]) == "" {
+		end--
+	}
+	if start == end {
+		return LineRange{}
+	}
+	return LineRange{Start: start + 1, End: end}
+}
+
+// Key returns the text that identifies the card in storage: its stable ID
+// if it has one, otherwise the question itself, qualified by deck or file
+// if another card has the same question.
+func (c Card) Key() string {
+	if c.ID != "" {
+		return "^" + c.ID
+	}
+	if c.Qualifier != "" {
+		return "@" + c.Qualifier + "\n" + c.Question
+	}
+	return c.Question
+}
 
-	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil // skip inaccessible files
+// parseSeparator reports whether line is a card separator: a lone "?", or
+// "??" for a reversible card, optionally followed by a stable ID marker
+// such as "? ^go-channels".
+func parseSeparator(line string) (id string, reverse bool, ok bool) {
+	trimmed := strings.TrimSpace(line)
+	rest, found := strings.CutPrefix(trimmed, "??")
+	if found {
+		reverse = true
+	} else if rest, found = strings.CutPrefix(trimmed, "?"); !found {
+		return "", false, false
+	}
+	rest = strings.TrimSpace(rest)
+	if rest == "" {
+		return "", reverse, true
+	}
+	if !strings.HasPrefix(rest, "^") || len(rest) == 1 {
+		return "", false, false
+	}
+	for _, r := range rest[1:] {
+		if !isIDRune(r) {
+			return "", false, false
 		}
-		if info.IsDir() {
-			// skip hidden directories
-			if strings.HasPrefix(info.Name(), ".") {
-				return filepath.SkipDir
+	}
+	return rest[1:], reverse, true
+}
+
+func isIDRune(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_'
+}
+
+// Options controls ParseDirectoryWith.
+type Options struct {
+	// Cache, if set, supplies cards for unchanged files and records the rest.
+	Cache *Cache
+	// Workers is how many files are parsed at once; 0 means GOMAXPROCS.
+	Workers int
+
+	// Include, if not empty, limits parsing to files matching one of these
+	// globs; Exclude skips matching files and directories. Globs are relative
+	// to the root and use .gitignore syntax.
+	Include []string
+	Exclude []string
+	// FollowSymlinks descends into symlinked directories, each real
+	// directory at most once. Symlinked files are always read.
+	FollowSymlinks bool
+}
+
+// ParseDirectory parses every markdown file under root, skipping hidden
+// directories and whatever .gitignore and .ignore files rule out. Files are
+// parsed concurrently, but cards come back in the lexical order of their
+// files, as filepath.WalkDir visits them. Files that can't be read are
+// skipped; use ParseDirectoryWith to find out about them.
+func ParseDirectory(root string) ([]Card, error) {
+	cards, _, err := ParseDirectoryWith(root, Options{})
+	return cards, err
+}
+
+// ParseDirectoryWith is ParseDirectory with options. It also returns
+// diagnostics for unreadable files and suspicious card syntax, sorted by
+// file and line.
+func ParseDirectoryWith(root string, opts Options) ([]Card, []Diagnostic, error) {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	paths, diags, err := findNotes(root, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if opts.Cache != nil {
+		opts.Cache.prune(root, paths)
+	}
+
+	// Each worker writes only its own files' slots, so no locking is needed
+	// and the output order doesn't depend on scheduling.
+	results := make([][]Card, len(paths))
+	fileDiags := make([][]Diagnostic, len(paths))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range max(1, min(workers, len(paths))) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fileCards, d, err := parseFileCached(paths[i], opts.Cache)
+				if err != nil {
+					// skip unreadable files
+					fileDiags[i] = []Diagnostic{{File: paths[i], Severity: SeverityError, Message: err.Error()}}
+					continue
+				}
+				results[i], fileDiags[i] = fileCards, d
 			}
-			return nil
-		}
-		if !strings.HasSuffix(info.Name(), ".md") {
-			return nil
-		}
+		}()
+	}
+	for i := range paths {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
 
-		fileCards, err := parseFile(path)
-		if err != nil {
-			return nil // skip unparseable files
-		}
+	var cards []Card
+	for i, fileCards := range results {
 		cards = append(cards, fileCards...)
-		return nil
-	})
+		diags = append(diags, fileDiags[i]...)
+	}
+	diags = append(diags, duplicateDiagnostics(cards)...)
+	SortDiagnostics(diags)
+	disambiguate(cards, root)
+	return cards, diags, nil
+}
 
-	return cards, err
+// ReplaceFile re-parses path, one of the files cards were parsed from
+// under root, and returns cards with that file's cards replaced by the new
+// ones, in the same place. Duplicate questions are qualified again across
+// the whole set. The diagnostics are the file's own.
+func ReplaceFile(cards []Card, root, path string) ([]Card, []Diagnostic, error) {
+	fileCards, diags, err := parseFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := make([]Card, 0, len(cards)+len(fileCards))
+	inserted := false
+	for _, c := range cards {
+		if c.SourceFile != path {
+			result = append(result, c)
+			continue
+		}
+		if !inserted {
+			result = append(result, fileCards...)
+			inserted = true
+		}
+	}
+	if !inserted {
+		result = append(result, fileCards...)
+	}
+	disambiguate(result, root)
+	return result, diags, nil
 }
 
-func parseFile(path string) ([]Card, error) {
-	f, err := os.Open(path)
+// parseFile parses one note. The error is for a file that can't be read;
+// problems with its content come back as diagnostics.
+func parseFile(path string) ([]Card, []Diagnostic, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	defer func() { _ = f.Close() }()
+	lines := splitLines(string(data))
 
-	scanner := bufio.NewScanner(f)
-	var lines []string
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+	// Broken frontmatter is reported, and the body read as if there were none.
+	var diags []Diagnostic
+	meta, n, err := parseFrontmatter(lines)
+	if err != nil {
+		diags = append(diags, Diagnostic{File: path, Line: 1, Severity: SeverityError, Message: "frontmatter: " + err.Error()})
+		meta = Meta{}
 	}
-	if err := scanner.Err(); err != nil {
-		return nil, err
+	// Blank the frontmatter out rather than dropping it, so line indexes
+	// still match the file.
+	for i := 0; i < n; i++ {
+		lines[i] = ""
 	}
 
-	// Find the #flashcards tag and extract deck name
-	deck := findDeck(lines)
+	// Frontmatter decides the file's deck; otherwise the first #flashcards
+	// tag anywhere in it. Tags under a heading override that for their
+	// section only.
+	deck := meta.deck()
 	if deck == "" {
-		return nil, nil // no flashcards tag found
+		deck = findDeck(lines)
 	}
+	if deck == "" {
+		return nil, diags, nil // no flashcards tag found
+	}
+
+	cards, cardDiags := extractCards(lines, deck, path)
+	for i := range cards {
+		cards[i].Meta = meta
+	}
+	return cards, append(diags, cardDiags...), nil
+}
 
-	return extractCards(lines, deck, path), nil
+// splitLines splits text into lines the way bufio.ScanLines does, dropping
+// "\r" line endings, but with no limit on line length.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines
 }
 
 func findDeck(lines []string) string {
-	for _, line := range lines {
+	verbatim := verbatimLines(lines)
+	for i, line := range lines {
+		if verbatim[i] {
+			continue // a tag inside a code sample doesn't count
+		}
 		trimmed := strings.TrimSpace(line)
 		// look for #flashcards tag anywhere in the line
 		for _, word := range strings.Fields(trimmed) {
-			if word == "#flashcards" {
-				return "default"
-			}
-			if strings.HasPrefix(word, "#flashcards/") {
-				// extract deck name: everything after #flashcards/
-				deck := strings.TrimPrefix(word, "#flashcards/")
-				// replace / with . for nested decks
-				deck = strings.ReplaceAll(deck, "/", ".")
+			if deck, ok := deckFromTag(word); ok {
 				return deck
 			}
 		}
@@ -88,7 +276,22 @@ func findDeck(lines []string) string {
 	return ""
 }
 
-func extractCards(lines []string, deck, sourceFile string) []Card {
+// deckFromTag returns the deck named by a #flashcards tag: "default" for a
+// bare #flashcards, otherwise the path after it with / turned into dots.
+func deckFromTag(word string) (string, bool) {
+	if word == "#flashcards" {
+		return "default", true
+	}
+	if strings.HasPrefix(word, "#flashcards/") {
+		// extract deck name: everything after #flashcards/
+		deck := strings.TrimPrefix(word, "#flashcards/")
+		// replace / with . for nested decks
+		return strings.ReplaceAll(deck, "/", "."), true
+	}
+	return "", false
+}
+
+func extractCards(lines []string, deck, sourceFile string) ([]Card, []Diagnostic) {
 	// Strategy: find all ? separators, then for each one:
 	// - Question = lines before the ?, going back to the previous card boundary
 	// - Answer = lines after the ?, going forward to the next card boundary
@@ -97,17 +300,25 @@ func extractCards(lines []string, deck, sourceFile string) []Card {
 	// the LAST group of contiguous non-blank lines before a ? is that card's question;
 	// everything before that group (after the previous ?) is the previous card's answer.
 
+	// Nothing inside code, math or comments is card syntax.
+	verbatim := verbatimLines(lines)
+	// Headings may narrow the deck for their section.
+	scopes := sectionScopes(lines, verbatim, deck)
+
 	var sepIndices []int
+	var sepIDs []string
+	var sepReverse []bool
 	for i, line := range lines {
-		if strings.TrimSpace(line) == "?" {
+		if verbatim[i] {
+			continue
+		}
+		if id, reverse, ok := parseSeparator(line); ok {
 			sepIndices = append(sepIndices, i)
+			sepIDs = append(sepIDs, id)
+			sepReverse = append(sepReverse, reverse)
 		}
 	}
 
-	if len(sepIndices) == 0 {
-		return nil
-	}
-
 	// For each ? at index sepIndices[i], find the question start.
 	// The question is the last block of non-blank, non-tag content lines
 	// before the ?, bounded by either: a blank line, a #review-flashcard tag,
@@ -115,6 +326,8 @@ func extractCards(lines []string, deck, sourceFile string) []Card {
 	type cardRange struct {
 		qStart, qEnd int // question line range [qStart, qEnd)
 		aStart, aEnd int // answer line range [aStart, aEnd)
+		id           string
+		reverse      bool
 	}
 
 	var ranges []cardRange
@@ -132,16 +345,18 @@ func extractCards(lines []string, deck, sourceFile string) []Card {
 		qEnd := sepIdx // exclusive
 
 		// Skip trailing blanks before ?
-		for qEnd > regionStart && strings.TrimSpace(lines[qEnd-1]) == "" {
+		for qEnd > regionStart && isBlank(lines, verbatim, qEnd-1) {
 			qEnd--
 		}
 
 		// Now walk backwards to find the start of the question block
 		qStart := qEnd
 		for qStart > regionStart {
-			trimmed := strings.TrimSpace(lines[qStart-1])
-			if trimmed == "" || trimmed == "#review-flashcard" || containsFlashcardsTag(trimmed) {
-				break
+			if !verbatim[qStart-1] {
+				trimmed := strings.TrimSpace(lines[qStart-1])
+				if trimmed == "" || trimmed == "#review-flashcard" || containsFlashcardsTag(trimmed) || isHeading(lines[qStart-1]) {
+					break
+				}
 			}
 			qStart--
 		}
@@ -152,18 +367,23 @@ func extractCards(lines []string, deck, sourceFile string) []Card {
 
 		// If there's a next separator, the answer ends where that card's question starts
 		// We'll fix this up in a second pass after computing all question ranges
-		ranges = append(ranges, cardRange{qStart, qEnd, aStart, aEnd})
-	}
-
-	// Fix up answer end: each answer ends where the next card's question starts
-	for i := 0; i < len(ranges)-1; i++ {
-		// The next card's question might have #review-flashcard before it
-		// Answer ends at the first #review-flashcard or the question start, whichever is earlier
-		nextQStart := ranges[i+1].qStart
-		// Check if there's a #review-flashcard between our answer start and next question
-		aEnd := nextQStart
-		for j := ranges[i].aStart; j < nextQStart; j++ {
-			if strings.TrimSpace(lines[j]) == "#review-flashcard" {
+		ranges = append(ranges, cardRange{qStart, qEnd, aStart, aEnd, sepIDs[si], sepReverse[si]})
+	}
+
+	// Fix up answer end: each answer ends where the next card's question
+	// starts, or earlier at a #review-flashcard marker, a heading or a deck
+	// tag, none of which belong to the answer.
+	for i := range ranges {
+		aEnd := len(lines)
+		if i+1 < len(ranges) {
+			aEnd = ranges[i+1].qStart
+		}
+		for j := ranges[i].aStart; j < aEnd; j++ {
+			if verbatim[j] {
+				continue
+			}
+			trimmed := strings.TrimSpace(lines[j])
+			if trimmed == "#review-flashcard" || isHeading(lines[j]) || containsFlashcardsTag(trimmed) {
 				aEnd = j
 				break
 			}
@@ -171,8 +391,29 @@ func extractCards(lines []string, deck, sourceFile string) []Card {
 		ranges[i].aEnd = aEnd
 	}
 
-	var cards []Card
+	// An Anki-style cloze paragraph, or one made only of inline cards, is
+	// never part of an answer: the answer stops at the blank line before it.
+	for i := range ranges {
+		for j := ranges[i].aStart + 1; j < ranges[i].aEnd; j++ {
+			if isBlank(lines, verbatim, j-1) && !verbatim[j] && startsOwnCards(paragraphAt(lines, verbatim, j)) {
+				ranges[i].aEnd = j
+				break
+			}
+		}
+	}
+
+	// Lines in a ? card or in a verbatim block can't hold inline or cloze cards.
+	covered := make([]bool, len(lines))
+	copy(covered, verbatim)
 	for _, r := range ranges {
+		for i := r.qStart; i < r.aEnd; i++ {
+			covered[i] = true
+		}
+	}
+
+	var cards []Card
+	var diags []Diagnostic
+	for si, r := range ranges {
 		var qLines, aLines []string
 		for i := r.qStart; i < r.qEnd; i++ {
 			qLines = append(qLines, lines[i])
@@ -181,19 +422,99 @@ func extractCards(lines []string, deck, sourceFile string) []Card {
 			aLines = append(aLines, lines[i])
 		}
 
-		q := strings.TrimSpace(strings.Join(qLines, "\n"))
+		q, typed := cutTypeAnswerTag(strings.Join(qLines, "\n"))
 		a := strings.TrimSpace(strings.Join(aLines, "\n"))
-		if q != "" {
-			cards = append(cards, Card{
-				DeckName:   deck,
-				Question:   q,
-				Answer:     a,
-				SourceFile: sourceFile,
+		if q == "" {
+			diags = append(diags, Diagnostic{
+				File:     sourceFile,
+				Line:     sepIndices[si] + 1,
+				Severity: SeverityWarning,
+				Message:  "separator has no question above it",
+			})
+			continue
+		}
+		if a == "" {
+			diags = append(diags, Diagnostic{
+				File:     sourceFile,
+				Line:     sepIndices[si] + 1,
+				Severity: SeverityWarning,
+				Message:  "card has no answer",
 			})
 		}
+		forward := Card{
+			DeckName:      scopes[r.qStart].deck,
+			Question:      q,
+			Answer:        a,
+			SourceFile:    sourceFile,
+			QuestionLines: textRange(lines, r.qStart, r.qEnd),
+			AnswerLines:   textRange(lines, r.aStart, r.aEnd),
+			ID:            r.id,
+			Position:      len(cards),
+			Headings:      scopes[r.qStart].headings,
+			TypeAnswer:    typed,
+		}
+		if !r.reverse || a == "" {
+			cards = append(cards, forward)
+			continue
+		}
+
+		cards = append(cards, reversePair(forward)...)
 	}
 
-	return cards
+	cards = append(cards, extractInline(lines, covered, scopes, sourceFile, len(cards))...)
+	cards = append(cards, extractClozes(lines, covered, scopes, sourceFile, len(cards))...)
+	return cards, diags
+}
+
+// isHeading reports whether line is a markdown heading.
+func isHeading(line string) bool {
+	_, _, ok := parseHeading(line)
+	return ok
+}
+
+// reversePair returns forward and its answer-to-question twin, placed right
+// after it and linked as siblings.
+func reversePair(forward Card) []Card {
+	backward := forward
+	backward.Question, backward.Answer = forward.Answer, forward.Question
+	backward.QuestionLines, backward.AnswerLines = forward.AnswerLines, forward.QuestionLines
+	backward.Reverse = true
+	backward.Position++
+	if forward.ID != "" {
+		backward.ID = forward.ID + "-reverse"
+	}
+	forward.Sibling = backward.Key()
+	backward.Sibling = forward.Key()
+	return []Card{forward, backward}
+}
+
+// typeAnswerTag, anywhere in a question, asks for the answer to be typed
+// in rather than recalled.
+const typeAnswerTag = "#type-answer"
+
+// cutTypeAnswerTag removes #type-answer tags from text and reports whether
+// there were any. Lines left empty by it are dropped, and the result is
+// trimmed.
+func cutTypeAnswerTag(text string) (string, bool) {
+	if !strings.Contains(text, typeAnswerTag) {
+		return strings.TrimSpace(text), false
+	}
+	found := false
+	var kept []string
+	for _, line := range strings.Split(text, "\n") {
+		words := strings.Fields(line)
+		if !slices.Contains(words, typeAnswerTag) {
+			kept = append(kept, line)
+			continue
+		}
+		found = true
+		words = slices.DeleteFunc(words, func(w string) bool { return w == typeAnswerTag })
+		if len(words) > 0 {
+			indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			kept = append(kept, indent+strings.Join(words, " "))
+		}
+	}
+	return strings.TrimSpace(strings.Join(kept, "\n")), found
 }
 
 func containsFlashcardsTag(line string) bool {
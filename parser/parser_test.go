@@ -3,6 +3,7 @@ package parser
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -128,7 +129,7 @@ func TestExtractCards(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			cards := extractCards(tt.lines, "test-deck", "test.md")
+			cards, _ := extractCards(tt.lines, "test-deck", "test.md")
 			if len(cards) != tt.want {
 				t.Fatalf("got %d cards, want %d", len(cards), tt.want)
 			}
@@ -210,3 +211,307 @@ Hidden
 		}
 	}
 }
+
+func TestParseSeparator(t *testing.T) {
+	tests := []struct {
+		line        string
+		wantID      string
+		wantReverse bool
+		wantOK      bool
+	}{
+		{"?", "", false, true},
+		{"  ?  ", "", false, true},
+		{"? ^go-channels", "go-channels", false, true},
+		{"?^abc_1", "abc_1", false, true},
+		{"??", "", true, true},
+		{"?? ^vocab-cat", "vocab-cat", true, true},
+		{"???", "", false, false},
+		{"? ^", "", false, false},
+		{"? ^has space", "", false, false},
+		{"? not an id", "", false, false},
+		{"What?", "", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			id, reverse, ok := parseSeparator(tt.line)
+			if ok != tt.wantOK || id != tt.wantID || reverse != tt.wantReverse {
+				t.Errorf("parseSeparator(%q) = (%q, %v, %v), want (%q, %v, %v)",
+					tt.line, id, reverse, ok, tt.wantID, tt.wantReverse, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestExtractCardsStableID(t *testing.T) {
+	lines := []string{
+		"What is a goroutine?",
+		"? ^goroutine",
+		"A lightweight thread",
+		"",
+		"What is a channel?",
+		"?",
+		"A typed conduit",
+	}
+
+	cards, _ := extractCards(lines, "go", "go.md")
+	if len(cards) != 2 {
+		t.Fatalf("got %d cards, want 2", len(cards))
+	}
+	if cards[0].ID != "goroutine" || cards[0].Key() != "^goroutine" {
+		t.Errorf("first card ID = %q, key = %q", cards[0].ID, cards[0].Key())
+	}
+	if cards[1].ID != "" || cards[1].Key() != cards[1].Question {
+		t.Errorf("card without marker should be keyed by question, got %q", cards[1].Key())
+	}
+	if cards[0].Position != 0 || cards[1].Position != 1 {
+		t.Errorf("positions = %d, %d, want 0, 1", cards[0].Position, cards[1].Position)
+	}
+}
+
+func TestExtractCardsReversible(t *testing.T) {
+	lines := []string{
+		"der Hund",
+		"??",
+		"the dog",
+		"",
+		"die Katze",
+		"?? ^katze",
+		"the cat",
+	}
+
+	cards, _ := extractCards(lines, "german", "german.md")
+	if len(cards) != 4 {
+		t.Fatalf("got %d cards, want 4", len(cards))
+	}
+
+	fwd, rev := cards[0], cards[1]
+	if fwd.Question != "der Hund" || fwd.Answer != "the dog" || fwd.Reverse {
+		t.Errorf("forward card = %+v", fwd)
+	}
+	if rev.Question != "the dog" || rev.Answer != "der Hund" || !rev.Reverse {
+		t.Errorf("reverse card = %+v", rev)
+	}
+	if fwd.Key() == rev.Key() {
+		t.Error("forward and reverse cards must have distinct keys")
+	}
+	if fwd.Sibling != rev.Key() || rev.Sibling != fwd.Key() {
+		t.Errorf("siblings = %q / %q", fwd.Sibling, rev.Sibling)
+	}
+
+	if cards[2].Key() != "^katze" || cards[3].Key() != "^katze-reverse" {
+		t.Errorf("ID keys = %q, %q", cards[2].Key(), cards[3].Key())
+	}
+	for i, c := range cards {
+		if c.Position != i {
+			t.Errorf("card %d position = %d", i, c.Position)
+		}
+	}
+}
+
+func TestExtractCardsLineRanges(t *testing.T) {
+	lines := []string{
+		"#flashcards/go",   // 1
+		"",                 // 2
+		"What does",        // 3
+		"defer do?",        // 4
+		"?",                // 5
+		"",                 // 6
+		"Runs a call",      // 7
+		"on return.",       // 8
+		"",                 // 9
+		"Hund",             // 10
+		"??",               // 11
+		"dog",              // 12
+		"",                 // 13
+		"nil::zero value",  // 14
+		"",                 // 15
+		"Go ==compiles==.", // 16
+		"Fast too.",        // 17
+	}
+
+	cards, _ := extractCards(lines, "go", "go.md")
+
+	want := []struct {
+		question string
+		q, a     LineRange
+	}{
+		{"What does\ndefer do?", LineRange{3, 4}, LineRange{7, 8}},
+		{"Hund", LineRange{10, 10}, LineRange{12, 12}},
+		{"dog", LineRange{12, 12}, LineRange{10, 10}},
+		{"nil", LineRange{14, 14}, LineRange{14, 14}},
+		{"Go [...].\nFast too.", LineRange{16, 17}, LineRange{16, 17}},
+	}
+	if len(cards) != len(want) {
+		t.Fatalf("got %d cards, want %d", len(cards), len(want))
+	}
+	for i, w := range want {
+		c := cards[i]
+		if c.Question != w.question || c.QuestionLines != w.q || c.AnswerLines != w.a {
+			t.Errorf("card[%d] %q: lines %v/%v, want %q: %v/%v",
+				i, c.Question, c.QuestionLines, c.AnswerLines, w.question, w.q, w.a)
+		}
+	}
+	if got := cards[0].Location(); got != "go.md:3" {
+		t.Errorf("Location() = %q, want go.md:3", got)
+	}
+}
+
+func TestExtractCardsTypeAnswer(t *testing.T) {
+	lines := []string{
+		"#flashcards/german",
+		"",
+		"#type-answer",
+		"dog (German)",
+		"?",
+		"der Hund",
+		"",
+		"cat (German) #type-answer",
+		"??",
+		"die Katze",
+		"",
+		"house (German)",
+		"?",
+		"das Haus",
+		"",
+		"tree::der Baum #type-answer ^tree",
+	}
+
+	cards, _ := extractCards(lines, "german", "german.md")
+
+	want := []struct {
+		question, answer, id string
+		typed                bool
+	}{
+		{"dog (German)", "der Hund", "", true},
+		{"cat (German)", "die Katze", "", true},
+		{"die Katze", "cat (German)", "", true},
+		{"house (German)", "das Haus", "", false},
+		{"tree", "der Baum", "tree", true},
+	}
+	if len(cards) != len(want) {
+		t.Fatalf("got %d cards, want %d", len(cards), len(want))
+	}
+	for i, w := range want {
+		c := cards[i]
+		if c.Question != w.question || c.Answer != w.answer || c.ID != w.id || c.TypeAnswer != w.typed {
+			t.Errorf("card[%d] = %q/%q ^%s typed=%v, want %q/%q ^%s typed=%v",
+				i, c.Question, c.Answer, c.ID, c.TypeAnswer, w.question, w.answer, w.id, w.typed)
+		}
+	}
+}
+
+func TestCutTypeAnswerTag(t *testing.T) {
+	tests := []struct {
+		name, text, want string
+		found            bool
+	}{
+		{"no tag", "  plain question ", "plain question", false},
+		{"own line", "#type-answer\nquestion", "question", true},
+		{"end of line", "question #type-answer", "question", true},
+		{"keeps indentation", "list:\n  - item #type-answer", "list:\n  - item", true},
+		{"longer tag is not the marker", "question #type-answers", "question #type-answers", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := cutTypeAnswerTag(tt.text)
+			if got != tt.want || found != tt.found {
+				t.Errorf("cutTypeAnswerTag(%q) = %q, %v, want %q, %v", tt.text, got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
+
+func TestReplaceFile(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a.md": "#flashcards/go\n\nWhat is a map?\n?\nA hash table.\n",
+		"b.md": "#flashcards/rust\n\nWhat is a map?\n?\nHashMap.\n",
+		"c.md": "#flashcards/misc\n\nLast\n?\nC\n",
+	})
+	cards, _, err := ParseDirectoryWith(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cards[0].Qualifier == "" {
+		t.Fatal("duplicate question was not qualified")
+	}
+
+	edited := filepath.Join(dir, "b.md")
+	writeTree(t, dir, map[string]string{
+		"b.md": "#flashcards/rust\n\nWhat is a HashMap?\n?\nA map.\n\nWhat is a Vec?\n?\nA vector.\n\nWhat is Go?\n?\n\n",
+	})
+	got, diags, err := ReplaceFile(cards, dir, edited)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var questions []string
+	for _, c := range got {
+		questions = append(questions, c.Question)
+		if c.Qualifier != "" {
+			t.Errorf("%q: qualifier %q left over from the old duplicate", c.Question, c.Qualifier)
+		}
+	}
+	want := []string{"What is a map?", "What is a HashMap?", "What is a Vec?", "What is Go?", "Last"}
+	if strings.Join(questions, "|") != strings.Join(want, "|") {
+		t.Errorf("questions = %q, want %q", questions, want)
+	}
+	if len(diags) != 1 || diags[0].File != edited {
+		t.Errorf("diagnostics = %v, want the edited file's empty answer", diags)
+	}
+}
+
+func TestParseFileLineRangesAfterFrontmatter(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "go.md")
+	content := "---\ndeck: go\n---\n\nQ\n?\nA\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cards, _, err := parseFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cards) != 1 {
+		t.Fatalf("got %d cards, want 1", len(cards))
+	}
+	if cards[0].QuestionLines != (LineRange{5, 5}) || cards[0].AnswerLines != (LineRange{7, 7}) {
+		t.Errorf("lines = %v/%v, want {5 5}/{7 7}", cards[0].QuestionLines, cards[0].AnswerLines)
+	}
+}
+
+func TestParseDirectoryOrder(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.md", "a.md", "sub/c.md", "sub/a.md", "z.md"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		content := "#flashcards\n\n" + name + " one\n?\nA\n\n" + name + " two\n?\nB\n"
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{
+		"a.md one", "a.md two", "b.md one", "b.md two",
+		"sub/a.md one", "sub/a.md two", "sub/c.md one", "sub/c.md two",
+		"z.md one", "z.md two",
+	}
+
+	for _, workers := range []int{1, 3, 16} {
+		cards, _, err := ParseDirectoryWith(dir, Options{Workers: workers})
+		if err != nil {
+			t.Fatalf("workers=%d: %v", workers, err)
+		}
+		var got []string
+		for _, c := range cards {
+			got = append(got, c.Question)
+		}
+		if strings.Join(got, "|") != strings.Join(want, "|") {
+			t.Errorf("workers=%d: questions = %q, want %q", workers, got, want)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package parser
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// walker collects the markdown files under a notes root, in lexical order.
+type walker struct {
+	root           string
+	include        []pattern
+	exclude        []pattern
+	followSymlinks bool
+
+	ignores map[string][]pattern // patterns from ignore files, by directory
+	visited map[string]bool      // real paths of walked directories, so symlink loops end
+	paths   []string
+	diags   []Diagnostic
+}
+
+// findNotes returns the markdown files under root that opts selects, and
+// diagnostics for the parts of the tree it could not read.
+func findNotes(root string, opts Options) ([]string, []Diagnostic, error) {
+	w := &walker{
+		root:           filepath.Clean(root),
+		include:        compilePatterns(opts.Include),
+		exclude:        compilePatterns(opts.Exclude),
+		followSymlinks: opts.FollowSymlinks,
+		ignores:        make(map[string][]pattern),
+		visited:        make(map[string]bool),
+	}
+	start := w.root
+	if info, err := os.Lstat(start); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+		start += string(filepath.Separator) // the root itself is always followed
+	}
+	if err := filepath.WalkDir(start, w.visit); err != nil {
+		return nil, nil, err
+	}
+	return w.paths, w.diags, nil
+}
+
+func (w *walker) visit(path string, d fs.DirEntry, err error) error {
+	if err != nil {
+		// skip inaccessible files
+		w.diags = append(w.diags, Diagnostic{File: path, Severity: SeverityError, Message: err.Error()})
+		return nil
+	}
+	// A followed symlink is walked as "link/", so WalkDir resolves it.
+	path = filepath.Clean(path)
+
+	if d.Type()&fs.ModeSymlink != 0 {
+		info, err := os.Stat(path)
+		if err != nil {
+			if strings.HasSuffix(path, ".md") {
+				w.diags = append(w.diags, Diagnostic{File: path, Severity: SeverityError, Message: err.Error()})
+			}
+			return nil // dangling link
+		}
+		if !info.IsDir() {
+			return w.file(path) // symlinked notes are read like any other
+		}
+		if !w.followSymlinks || w.skipDir(path) {
+			return nil
+		}
+		return filepath.WalkDir(path+string(filepath.Separator), w.visit)
+	}
+
+	if d.IsDir() {
+		if path != w.root && w.skipDir(path) {
+			return filepath.SkipDir
+		}
+		if real, err := filepath.EvalSymlinks(path); err == nil {
+			if w.visited[real] {
+				return filepath.SkipDir // already walked, through another link
+			}
+			w.visited[real] = true
+		}
+		if patterns := readIgnoreFiles(path); len(patterns) > 0 {
+			w.ignores[path] = patterns
+		}
+		return nil
+	}
+
+	return w.file(path)
+}
+
+// skipDir reports whether the directory at path is hidden, ignored or excluded.
+func (w *walker) skipDir(path string) bool {
+	return strings.HasPrefix(filepath.Base(path), ".") || w.ignored(path, true)
+}
+
+func (w *walker) file(path string) error {
+	if !strings.HasSuffix(path, ".md") || w.ignored(path, false) {
+		return nil
+	}
+	if len(w.include) > 0 && !matchPatterns(w.include, relPath(w.root, path), false, false) {
+		return nil
+	}
+	w.paths = append(w.paths, path)
+	return nil
+}
+
+// ignored reports whether an ignore file, or the exclude list, rules out
+// path. Ignore files deeper in the tree are applied last, so they win.
+func (w *walker) ignored(path string, isDir bool) bool {
+	var dirs []string
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if dir == w.root || dir == filepath.Dir(dir) {
+			break
+		}
+	}
+
+	ignored := false
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if patterns, ok := w.ignores[dirs[i]]; ok {
+			ignored = matchPatterns(patterns, relPath(dirs[i], path), isDir, ignored)
+		}
+	}
+	return matchPatterns(w.exclude, relPath(w.root, path), isDir, ignored)
+}
+
+// relPath returns path relative to base, slash-separated.
+func relPath(base, path string) string {
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,155 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// writeTree creates files (relative path → content) under dir.
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// relNotes returns findNotes' result relative to root.
+func relNotes(t *testing.T, root string, opts Options) []string {
+	t.Helper()
+	paths, _, err := findNotes(root, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rel []string
+	for _, p := range paths {
+		rel = append(rel, relPath(root, p))
+	}
+	return rel
+}
+
+func TestFindNotes(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		opts  Options
+		want  []string
+	}{
+		{
+			name: "gitignore and ignore files",
+			files: map[string]string{
+				".gitignore":                 "node_modules/\n# comment\n*.tmp.md\n",
+				".ignore":                    "/templates\n",
+				"a.md":                       "",
+				"b.tmp.md":                   "",
+				"node_modules/pkg/readme.md": "",
+				"templates/daily.md":         "",
+				"sub/templates/keep.md":      "",
+				".trash/deleted.md":          "",
+				"notes.txt":                  "",
+			},
+			want: []string{"a.md", "sub/templates/keep.md"},
+		},
+		{
+			name: "nested gitignore re-includes",
+			files: map[string]string{
+				".gitignore":          "draft-*.md\n",
+				"blog/.gitignore":     "!draft-ok.md\n",
+				"draft-1.md":          "",
+				"blog/draft-ok.md":    "",
+				"blog/draft-other.md": "",
+			},
+			want: []string{"blog/draft-ok.md"},
+		},
+		{
+			name: "nested gitignore is relative to its directory",
+			files: map[string]string{
+				"blog/.gitignore":   "/old\n",
+				"blog/old/a.md":     "",
+				"blog/new/old/b.md": "",
+				"old/c.md":          "",
+			},
+			want: []string{"blog/new/old/b.md", "old/c.md"},
+		},
+		{
+			name: "include and exclude globs",
+			files: map[string]string{
+				"cs/algo.md":          "",
+				"cs/archive/old.md":   "",
+				"cs/drafts/wip.md":    "",
+				"journal/2024-01.md":  "",
+				"languages/german.md": "",
+			},
+			opts: Options{
+				Include: []string{"cs/**", "languages/*.md"},
+				Exclude: []string{"archive/", "cs/drafts"},
+			},
+			want: []string{"cs/algo.md", "languages/german.md"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTree(t, dir, tt.files)
+			if got := relNotes(t, dir, tt.opts); !slices.Equal(got, tt.want) {
+				t.Errorf("notes = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindNotesSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "notes")
+	writeTree(t, dir, map[string]string{
+		"notes/a.md":    "",
+		"shared/s.md":   "",
+		"single/one.md": "",
+	})
+	links := map[string]string{
+		"notes/shared": filepath.Join(dir, "shared"),           // directory outside the root
+		"notes/loop":   root,                                   // back to the root
+		"notes/one.md": filepath.Join(dir, "single", "one.md"), // a single note
+		"notes/gone":   filepath.Join(dir, "missing"),          // dangling
+		"shared/again": filepath.Join(dir, "shared"),           // loops on itself
+		"notes/twice":  filepath.Join(dir, "shared"),           // same directory twice
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, filepath.Join(dir, link)); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+
+	t.Run("not followed", func(t *testing.T) {
+		want := []string{"a.md", "one.md"}
+		if got := relNotes(t, root, Options{}); !slices.Equal(got, want) {
+			t.Errorf("notes = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("followed once each", func(t *testing.T) {
+		want := []string{"a.md", "one.md", "shared/s.md"}
+		if got := relNotes(t, root, Options{FollowSymlinks: true}); !slices.Equal(got, want) {
+			t.Errorf("notes = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("symlinked root", func(t *testing.T) {
+		link := filepath.Join(dir, "root-link")
+		if err := os.Symlink(root, link); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"a.md", "one.md"}
+		if got := relNotes(t, link, Options{}); !slices.Equal(got, want) {
+			t.Errorf("notes = %q, want %q", got, want)
+		}
+	})
+}
@@ -0,0 +1,355 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Backend persists card state and the review log.
+type Backend interface {
+	// Load returns all card state and the review log. A backend that
+	// implements reviewQuerier may leave out reviews it can query instead.
+	Load() (map[string]CardState, []ReviewEvent, error)
+	// Save persists card state and appends events to the review log.
+	// changed lists the keys modified since the last Save; a changed key
+	// missing from cards was deleted. Changes made by other processes to
+	// keys not in changed are merged back into cards.
+	Save(cards map[string]CardState, changed []string, events []ReviewEvent) error
+	// Commit durably records the same kind of change as Save, but is meant
+	// to be called after every rating and so must be cheap.
+	Commit(cards map[string]CardState, changed []string, events []ReviewEvent) error
+	// Undo durably takes back a recorded rating: the card's state becomes
+	// state (deleted if nil), whatever is stored, and event leaves the log.
+	Undo(key string, state *CardState, event ReviewEvent) error
+	Close() error
+}
+
+// jsonBackend keeps card state in a single JSON file, rewritten atomically
+// on every save, and the review log in a JSON-lines file next to it.
+// Commits go to an append-only journal that Load replays and Save folds in.
+// Every access holds an advisory lock, so several processes can share the files.
+type jsonBackend struct {
+	path string
+}
+
+// journalEntry is one line of the journal: a card's new state (nil if it was
+// deleted) or a review event.
+type journalEntry struct {
+	Key   string       `json:"key,omitempty"`
+	State *CardState   `json:"state,omitempty"`
+	Event *ReviewEvent `json:"event,omitempty"`
+}
+
+// JournalPath returns the journal that sits next to the given state file.
+func JournalPath(statePath string) string {
+	return statePath + ".journal"
+}
+
+func (b *jsonBackend) Load() (map[string]CardState, []ReviewEvent, error) {
+	unlock, err := b.lock()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
+	cards, log, journaled, err := b.read()
+	if err != nil {
+		return nil, nil, err
+	}
+	return cards, append(log, journaled...), nil
+}
+
+// read returns what is on disk: the state file with the journal replayed on
+// top, the review log, and journaled events missing from the log.
+func (b *jsonBackend) read() (map[string]CardState, []ReviewEvent, []ReviewEvent, error) {
+	cards := make(map[string]CardState)
+
+	data, err := os.ReadFile(b.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &cards); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	log, err := loadLog(LogPath(b.path))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	journaled, err := b.replay(cards, log)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return cards, log, journaled, nil
+}
+
+// replay applies the journal left by sessions that have not reached Save
+// yet, or never will. Events already in the log (Save was interrupted after
+// appending them) are skipped; the rest are returned.
+func (b *jsonBackend) replay(cards map[string]CardState, log []ReviewEvent) ([]ReviewEvent, error) {
+	data, err := os.ReadFile(JournalPath(b.path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	seen := eventSet(log)
+	var events []ReviewEvent
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		var entry journalEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			if i == len(lines)-1 {
+				break // torn final write from a crash
+			}
+			return nil, fmt.Errorf("%s:%d: %w", JournalPath(b.path), i+1, err)
+		}
+		switch {
+		case entry.Event != nil:
+			if seen[eventID(*entry.Event)] {
+				continue
+			}
+			seen[eventID(*entry.Event)] = true
+			events = append(events, *entry.Event)
+		case entry.State != nil:
+			cards[entry.Key] = newest(cards[entry.Key], *entry.State)
+		default:
+			delete(cards, entry.Key)
+		}
+	}
+	return events, nil
+}
+
+func (b *jsonBackend) Commit(cards map[string]CardState, changed []string, events []ReviewEvent) error {
+	if len(changed) == 0 && len(events) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(b.path), 0755); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, key := range changed {
+		entry := journalEntry{Key: key}
+		if state, ok := cards[key]; ok {
+			entry.State = &state
+		}
+		if err := enc.Encode(entry); err != nil {
+			return err
+		}
+	}
+	for i := range events {
+		if err := enc.Encode(journalEntry{Event: &events[i]}); err != nil {
+			return err
+		}
+	}
+
+	unlock, err := b.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	f, err := os.OpenFile(JournalPath(b.path), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Save merges with whatever other processes wrote since this one loaded:
+// keys this process did not touch take the on-disk state, keys both touched
+// keep whichever was reviewed last.
+func (b *jsonBackend) Save(cards map[string]CardState, changed []string, events []ReviewEvent) error {
+	if err := os.MkdirAll(filepath.Dir(b.path), 0755); err != nil {
+		return err
+	}
+
+	unlock, err := b.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	disk, log, journaled, err := b.read()
+	if err != nil {
+		return err
+	}
+	merge(cards, disk, changed)
+
+	data, err := json.MarshalIndent(cards, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// State first, then the log, then drop the journal: a crash at any
+	// point leaves a journal whose replay restores what is missing.
+	if err := writeFileAtomic(b.path, data); err != nil {
+		return err
+	}
+
+	seen := eventSet(log)
+	var missing []ReviewEvent
+	for _, ev := range append(journaled, events...) {
+		if !seen[eventID(ev)] {
+			seen[eventID(ev)] = true
+			missing = append(missing, ev)
+		}
+	}
+	if err := appendLog(LogPath(b.path), missing); err != nil {
+		return err
+	}
+
+	if err := os.Remove(JournalPath(b.path)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Undo folds the journal in like Save, with key forced to state, and
+// rewrites the review log without event. Forcing matters: the journal and
+// the state file hold the rating's newer state, which a merge would keep.
+func (b *jsonBackend) Undo(key string, state *CardState, event ReviewEvent) error {
+	unlock, err := b.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	disk, log, journaled, err := b.read()
+	if err != nil {
+		return err
+	}
+	if state != nil {
+		disk[key] = *state
+	} else {
+		delete(disk, key)
+	}
+
+	data, err := json.MarshalIndent(disk, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(b.path, data); err != nil {
+		return err
+	}
+
+	undone := eventID(event)
+	var kept []ReviewEvent
+	for _, ev := range append(log, journaled...) {
+		if eventID(ev) != undone {
+			kept = append(kept, ev)
+		}
+	}
+	if err := writeLog(LogPath(b.path), kept); err != nil {
+		return err
+	}
+
+	if err := os.Remove(JournalPath(b.path)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (b *jsonBackend) Close() error {
+	return nil
+}
+
+func (b *jsonBackend) lock() (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(b.path), 0755); err != nil {
+		return nil, err
+	}
+	return lockFile(b.path + ".lock")
+}
+
+// merge folds disk state into ours. Keys in changed keep our state unless
+// disk holds a later review (a key we deleted stays deleted); every other
+// key takes the disk state.
+func merge(ours, disk map[string]CardState, changed []string) {
+	touched := make(map[string]bool, len(changed))
+	for _, key := range changed {
+		touched[key] = true
+		theirs, onDisk := disk[key]
+		mine, kept := ours[key]
+		if onDisk && kept {
+			ours[key] = newest(theirs, mine)
+		}
+	}
+
+	for key := range ours {
+		if _, ok := disk[key]; !ok && !touched[key] {
+			delete(ours, key)
+		}
+	}
+	for key, theirs := range disk {
+		if !touched[key] {
+			ours[key] = theirs
+		}
+	}
+}
+
+// newest returns b if it was reviewed at or after a, otherwise a.
+func newest(a, b CardState) CardState {
+	if a.LastReviewed.After(b.LastReviewed) {
+		return a
+	}
+	return b
+}
+
+func eventID(ev ReviewEvent) string {
+	return ev.Key + "@" + ev.Time.UTC().Format("2006-01-02T15:04:05.999999999")
+}
+
+func eventSet(events []ReviewEvent) map[string]bool {
+	seen := make(map[string]bool, len(events))
+	for _, ev := range events {
+		seen[eventID(ev)] = true
+	}
+	return seen
+}
+
+// writeFileAtomic replaces path with data via a synced temp file and rename,
+// so readers never see a half-written file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCommitSurvivesCrash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	store, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	store.Rate("q1", Good)
+	if err := store.Commit(); err != nil {
+		t.Fatalf("Commit() error: %v", err)
+	}
+	store.Rate("q2", Easy)
+	if err := store.Commit(); err != nil {
+		t.Fatalf("Commit() error: %v", err)
+	}
+	// no Save: the process dies here
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("Commit should not rewrite the state file")
+	}
+
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if reloaded.IsNew("q1") || reloaded.IsNew("q2") {
+		t.Error("committed ratings lost after crash")
+	}
+	if len(reloaded.Log) != 2 {
+		t.Fatalf("log has %d events, want 2", len(reloaded.Log))
+	}
+
+	// Saving folds the journal into the state file and review log.
+	if err := reloaded.Save(); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+	if _, err := os.Stat(JournalPath(path)); !os.IsNotExist(err) {
+		t.Error("journal should be removed after Save")
+	}
+	log, err := loadLog(LogPath(path))
+	if err != nil {
+		t.Fatalf("loadLog() error: %v", err)
+	}
+	if len(log) != 2 {
+		t.Errorf("review log has %d events, want 2", len(log))
+	}
+}
+
+func TestUndoCommitted(t *testing.T) {
+	tests := []struct {
+		name  string
+		saved bool // Save ran between the rating and the undo
+	}{
+		{"committed", false},
+		{"saved", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "state.json")
+
+			store, err := Load(path)
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			store.Rate("q1", Good)
+			store.Rate("q2", Good)
+			if err := store.Commit(); err != nil {
+				t.Fatalf("Commit() error: %v", err)
+			}
+			if tt.saved {
+				if err := store.Save(); err != nil {
+					t.Fatalf("Save() error: %v", err)
+				}
+			}
+			if _, err := store.Undo(); err != nil {
+				t.Fatalf("Undo() error: %v", err)
+			}
+			if err := store.Save(); err != nil {
+				t.Fatalf("Save() error: %v", err)
+			}
+
+			reloaded, err := Load(path)
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if reloaded.IsNew("q1") || !reloaded.IsNew("q2") {
+				t.Error("want q1 rated and q2 new after undoing q2")
+			}
+			if len(reloaded.Log) != 1 || reloaded.Log[0].Key != CardKey("q1") {
+				t.Errorf("log = %+v, want only the q1 rating", reloaded.Log)
+			}
+		})
+	}
+}
+
+func TestReplaySkipsLoggedEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	store, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	store.Rate("q1", Good)
+	if err := store.Commit(); err != nil {
+		t.Fatalf("Commit() error: %v", err)
+	}
+
+	// Simulate Save dying after appending to the log but before removing the journal.
+	journal, err := os.ReadFile(JournalPath(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+	if err := os.WriteFile(JournalPath(path), journal, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if len(reloaded.Log) != 1 {
+		t.Errorf("log has %d events, want 1", len(reloaded.Log))
+	}
+}
+
+func TestReplayIgnoresTornWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	store, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	store.Rate("q1", Good)
+	if err := store.Commit(); err != nil {
+		t.Fatalf("Commit() error: %v", err)
+	}
+
+	f, err := os.OpenFile(JournalPath(path), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"key":"abc","state":{"interv`); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if reloaded.IsNew("q1") {
+		t.Error("complete journal entries before the torn write were lost")
+	}
+}
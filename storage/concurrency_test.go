@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrentStoresMergeOnSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	a, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	b, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+
+	a.Rate("only a", Good)
+	b.Rate("only b", Good)
+
+	if err := a.Save(); err != nil {
+		t.Fatalf("a.Save() error: %v", err)
+	}
+	if err := b.Save(); err != nil {
+		t.Fatalf("b.Save() error: %v", err)
+	}
+
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if reloaded.IsNew("only a") || reloaded.IsNew("only b") {
+		t.Error("one session overwrote the other's ratings")
+	}
+	if len(reloaded.Log) != 2 {
+		t.Errorf("log has %d events, want 2", len(reloaded.Log))
+	}
+	if b.IsNew("only a") {
+		t.Error("Save should merge the other session's cards into memory")
+	}
+}
+
+func TestConcurrentStoresKeepNewestReview(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	a, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	b, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	a.SetClock(func() time.Time { return testNow.Add(time.Hour) })
+	b.SetClock(func() time.Time { return testNow })
+
+	a.Rate("shared", Easy) // later review
+	b.Rate("shared", Hard) // earlier review, saved last
+
+	if err := a.Save(); err != nil {
+		t.Fatalf("a.Save() error: %v", err)
+	}
+	if err := b.Save(); err != nil {
+		t.Fatalf("b.Save() error: %v", err)
+	}
+
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	got := reloaded.GetState("shared")
+	if !got.LastReviewed.Equal(testNow.Add(time.Hour)) {
+		t.Errorf("last reviewed = %v, want the later review", got.LastReviewed)
+	}
+}
+
+func TestConcurrentStoresCommitAndSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	const sessions, ratings = 4, 10
+	var wg sync.WaitGroup
+	errs := make(chan error, sessions)
+	for i := 0; i < sessions; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			store, err := Load(path)
+			if err != nil {
+				errs <- err
+				return
+			}
+			for j := 0; j < ratings; j++ {
+				store.Rate(fmt.Sprintf("session %d card %d", i, j), Good)
+				if err := store.Commit(); err != nil {
+					errs <- err
+					return
+				}
+			}
+			errs <- store.Save()
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("session error: %v", err)
+		}
+	}
+
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if len(reloaded.Cards) != sessions*ratings {
+		t.Errorf("got %d cards, want %d", len(reloaded.Cards), sessions*ratings)
+	}
+	if len(reloaded.Log) != sessions*ratings {
+		t.Errorf("log has %d events, want %d", len(reloaded.Log), sessions*ratings)
+	}
+}
+
+func TestConcurrentSQLiteStores(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+
+	a, err := OpenSQLite(path, "")
+	if err != nil {
+		t.Fatalf("OpenSQLite() error: %v", err)
+	}
+	defer func() { _ = a.Close() }()
+	b, err := OpenSQLite(path, "")
+	if err != nil {
+		t.Fatalf("OpenSQLite() error: %v", err)
+	}
+	defer func() { _ = b.Close() }()
+
+	a.SetClock(func() time.Time { return testNow.Add(time.Hour) })
+	b.SetClock(func() time.Time { return testNow })
+	a.Rate("only a", Good)
+	a.Rate("shared", Easy)
+	b.Rate("only b", Good)
+	b.Rate("shared", Hard)
+
+	if err := a.Save(); err != nil {
+		t.Fatalf("a.Save() error: %v", err)
+	}
+	if err := b.Save(); err != nil {
+		t.Fatalf("b.Save() error: %v", err)
+	}
+
+	reloaded, err := OpenSQLite(path, "")
+	if err != nil {
+		t.Fatalf("OpenSQLite() error: %v", err)
+	}
+	defer func() { _ = reloaded.Close() }()
+	if len(reloaded.Cards) != 3 {
+		t.Errorf("got %d cards, want 3", len(reloaded.Cards))
+	}
+	if got := reloaded.GetState("shared").LastReviewed; !got.Equal(testNow.Add(time.Hour)) {
+		t.Errorf("last reviewed = %v, want the later review", got)
+	}
+}
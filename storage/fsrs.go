@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"math"
+	"time"
+)
+
+// DefaultRetention is the FSRS target probability of recalling a card when it comes due.
+const DefaultRetention = 0.9
+
+// fsrsWeights are the FSRS-4.5 default parameters.
+var fsrsWeights = [17]float64{
+	0.4872, 1.4003, 3.7145, 13.8206, 5.1618, 1.2298, 0.8975, 0.031,
+	1.6474, 0.1367, 1.0461, 2.1072, 0.0793, 0.3246, 1.587, 0.2272, 2.8755,
+}
+
+const (
+	fsrsDecay  = -0.5
+	fsrsFactor = 19.0 / 81.0
+)
+
+// FSRSScheduler implements the Free Spaced Repetition Scheduler: each card
+// carries a stability and difficulty, and the next review is placed where
+// the forgetting curve drops to Retention.
+type FSRSScheduler struct {
+	Retention float64
+}
+
+// NewFSRSScheduler returns an FSRS scheduler targeting the given retention,
+// falling back to DefaultRetention if it is out of range.
+func NewFSRSScheduler(retention float64) FSRSScheduler {
+	if retention <= 0 || retention >= 1 {
+		retention = DefaultRetention
+	}
+	return FSRSScheduler{Retention: retention}
+}
+
+// UseFSRS switches the store to the FSRS scheduler with the given desired
+// retention. Cards that only carry SM-2 state get an initial stability and
+// difficulty derived from it.
+func (s *Store) UseFSRS(retention float64) {
+	s.SetScheduler(NewFSRSScheduler(retention))
+
+	for key, state := range s.Cards {
+		if state.Stability > 0 || state.Interval == 0 {
+			continue
+		}
+		s.set(key, migrateSM2(state))
+	}
+}
+
+// migrateSM2 seeds FSRS memory state from an SM-2 card. At 90% retention an
+// FSRS interval roughly equals stability, so the current interval is used
+// as-is; ease 1.3..3.0 maps linearly onto difficulty 10..1.
+func migrateSM2(state CardState) CardState {
+	state.Stability = float64(state.Interval)
+	state.Difficulty = clampDifficulty(10 - (state.EaseFactor-1.3)*9/1.7)
+	return state
+}
+
+func (f FSRSScheduler) IsDue(state CardState, now time.Time) bool {
+	return !now.Before(state.NextReview)
+}
+
+func (f FSRSScheduler) Preview(state CardState, now time.Time) map[Rating]int {
+	return previewIntervals(f, state, now)
+}
+
+func (f FSRSScheduler) Rate(state CardState, rating Rating, now time.Time) CardState {
+	w := fsrsWeights
+	g := float64(rating) + 1 // FSRS grades are 1 (Again) .. 4 (Easy)
+	first := state.Stability == 0
+
+	if first {
+		// First review
+		state.Stability = w[rating]
+		state.Difficulty = initialDifficulty(g)
+	} else {
+		elapsed := 0.0
+		if !state.LastReviewed.IsZero() {
+			elapsed = now.Sub(state.LastReviewed).Hours() / 24
+		}
+		r := retrievability(elapsed, state.Stability)
+		d := state.Difficulty
+
+		if rating == Again {
+			forgot := w[11] * math.Pow(d, -w[12]) * (math.Pow(state.Stability+1, w[13]) - 1) * math.Exp(w[14]*(1-r))
+			state.Stability = math.Min(forgot, state.Stability)
+		} else {
+			bonus := 1.0
+			switch rating {
+			case Hard:
+				bonus = w[15]
+			case Easy:
+				bonus = w[16]
+			}
+			state.Stability *= 1 + math.Exp(w[8])*(11-d)*math.Pow(state.Stability, -w[9])*(math.Exp(w[10]*(1-r))-1)*bonus
+		}
+
+		next := d - w[6]*(g-3)
+		state.Difficulty = clampDifficulty(w[7]*initialDifficulty(3) + (1-w[7])*next)
+	}
+
+	state.Interval = f.interval(state.Stability)
+	state.LastReviewed = now
+
+	if rating == Again {
+		if !first && !state.Relearning {
+			state.Lapses++
+		}
+		state.Relearning = true
+		state.NextReview = now
+	} else {
+		state.Relearning = false
+		state.NextReview = now.Add(time.Duration(state.Interval) * 24 * time.Hour)
+	}
+	return state
+}
+
+// retrievability is the probability of recall after elapsed days given stability.
+func retrievability(elapsed, stability float64) float64 {
+	return math.Pow(1+fsrsFactor*elapsed/stability, fsrsDecay)
+}
+
+// interval returns the number of days until recall probability drops to the target retention.
+func (f FSRSScheduler) interval(stability float64) int {
+	days := stability / fsrsFactor * (math.Pow(f.Retention, 1/fsrsDecay) - 1)
+	interval := int(math.Round(days))
+	if interval < 1 {
+		interval = 1
+	}
+	return interval
+}
+
+func initialDifficulty(g float64) float64 {
+	return clampDifficulty(fsrsWeights[4] - (g-3)*fsrsWeights[5])
+}
+
+func clampDifficulty(d float64) float64 {
+	return math.Max(1, math.Min(10, d))
+}
@@ -0,0 +1,98 @@
+package storage
+
+import "testing"
+
+func TestRateFSRS(t *testing.T) {
+	t.Run("first review seeds stability from rating", func(t *testing.T) {
+		store := newTestStore()
+		store.UseFSRS(0.9)
+
+		store.Rate("fsrs new", Good)
+
+		state := store.Cards[CardKey("fsrs new")]
+		if state.Stability != fsrsWeights[Good] {
+			t.Errorf("stability = %f, want %f", state.Stability, fsrsWeights[Good])
+		}
+		if state.Difficulty < 1 || state.Difficulty > 10 {
+			t.Errorf("difficulty = %f, want within [1, 10]", state.Difficulty)
+		}
+		if state.Interval < 1 {
+			t.Errorf("interval = %d, want >= 1", state.Interval)
+		}
+	})
+
+	t.Run("better ratings give longer intervals", func(t *testing.T) {
+		var intervals []int
+		for _, r := range []Rating{Hard, Good, Easy} {
+			store := newTestStore()
+			store.UseFSRS(0.9)
+			store.Cards[CardKey("q")] = CardState{
+				Stability:    10,
+				Difficulty:   5,
+				Interval:     10,
+				LastReviewed: testNow.AddDate(0, 0, -10),
+			}
+			store.Rate("q", r)
+			intervals = append(intervals, store.Cards[CardKey("q")].Interval)
+		}
+		if !(intervals[0] < intervals[1] && intervals[1] < intervals[2]) {
+			t.Errorf("intervals for hard/good/easy = %v, want strictly increasing", intervals)
+		}
+	})
+
+	t.Run("higher retention gives shorter intervals", func(t *testing.T) {
+		low := NewFSRSScheduler(0.8)
+		high := NewFSRSScheduler(0.95)
+
+		if low.interval(20) <= high.interval(20) {
+			t.Errorf("interval at 0.8 = %d, at 0.95 = %d; want 0.8 longer",
+				low.interval(20), high.interval(20))
+		}
+	})
+
+	t.Run("again lowers stability and counts a lapse", func(t *testing.T) {
+		store := newTestStore()
+		store.UseFSRS(0.9)
+		store.Cards[CardKey("lapse")] = CardState{
+			Stability:    30,
+			Difficulty:   5,
+			Interval:     30,
+			LastReviewed: testNow.AddDate(0, 0, -30),
+		}
+
+		store.Rate("lapse", Again)
+
+		state := store.Cards[CardKey("lapse")]
+		if state.Stability >= 30 {
+			t.Errorf("stability = %f, want < 30", state.Stability)
+		}
+		if state.Lapses != 1 {
+			t.Errorf("lapses = %d, want 1", state.Lapses)
+		}
+		if !store.IsDue("lapse") {
+			t.Error("lapsed card should be due immediately")
+		}
+	})
+}
+
+func TestUseFSRSMigratesSM2(t *testing.T) {
+	store := newTestStore()
+	store.Cards["learned"] = CardState{Interval: 12, EaseFactor: 2.5}
+	store.Cards["hard"] = CardState{Interval: 3, EaseFactor: 1.3}
+	store.Cards["fsrs"] = CardState{Interval: 4, EaseFactor: 2.5, Stability: 7, Difficulty: 2}
+
+	store.UseFSRS(0)
+
+	if got := store.Scheduler().(FSRSScheduler).Retention; got != DefaultRetention {
+		t.Errorf("retention = %f, want default %f", got, DefaultRetention)
+	}
+	if got := store.Cards["learned"].Stability; got != 12 {
+		t.Errorf("migrated stability = %f, want 12", got)
+	}
+	if store.Cards["hard"].Difficulty <= store.Cards["learned"].Difficulty {
+		t.Error("low ease card should migrate to higher difficulty")
+	}
+	if got := store.Cards["fsrs"].Stability; got != 7 {
+		t.Errorf("existing FSRS stability = %f, want unchanged 7", got)
+	}
+}
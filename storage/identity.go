@@ -0,0 +1,159 @@
+package storage
+
+// CardInfo describes a parsed card for Reconcile.
+type CardInfo struct {
+	Key        string // identity passed to Rate/IsDue: the question or a "^id" marker
+	Question   string
+	SourceFile string
+	Position   int
+}
+
+// minSimilarity is how close an edited question must be to an orphaned one
+// (1 = identical) for its review history to carry over.
+const minSimilarity = 0.75
+
+// Reconcile links review history to the current set of cards. Every known
+// card has its question, file and position recorded so it can be found
+// again later. A card without state inherits an orphaned entry, one no
+// current card uses, when either:
+//   - the card's key changed but the orphan is keyed by its question, as
+//     when it gains a stable ID or a duplicate-question qualifier, or
+//   - the orphan comes from the same file and its question is similar enough.
+//
+// Cards that shared one schedule under a duplicated question each get a
+// copy of it. Returns the number of cards that inherited state.
+func (s *Store) Reconcile(cards []CardInfo) int {
+	live := make(map[string]bool, len(cards))
+	for _, c := range cards {
+		live[CardKey(c.Key)] = true
+	}
+
+	orphans := make(map[string]bool)
+	for key := range s.Cards {
+		if !live[key] {
+			orphans[key] = true
+		}
+	}
+
+	migrated := 0
+	shared := make(map[string]bool) // orphans inherited by question, removed at the end
+	for _, c := range cards {
+		key := CardKey(c.Key)
+		if _, ok := s.Cards[key]; ok {
+			continue
+		}
+
+		if qKey := CardKey(c.Question); qKey != key && (orphans[qKey] || shared[qKey]) {
+			s.set(key, s.Cards[qKey])
+			delete(orphans, qKey)
+			shared[qKey] = true
+			migrated++
+			continue
+		}
+
+		from := s.closestOrphan(c, orphans)
+		if from == "" {
+			continue
+		}
+		s.set(key, s.Cards[from])
+		s.remove(from)
+		delete(orphans, from)
+		migrated++
+	}
+	for key := range shared {
+		s.remove(key)
+	}
+
+	for _, c := range cards {
+		key := CardKey(c.Key)
+		state, ok := s.Cards[key]
+		if !ok {
+			continue
+		}
+		if state.Question == c.Question && state.SourceFile == c.SourceFile && state.Position == c.Position {
+			continue
+		}
+		state.Question = c.Question
+		state.SourceFile = c.SourceFile
+		state.Position = c.Position
+		s.set(key, state)
+	}
+
+	return migrated
+}
+
+// Rekey moves the state of the card identified by from (a question or
+// "^id" marker, as passed to Rate) to the card identified by to, for when
+// the caller knows they are the same card. It does nothing if to already
+// has state, and reports whether anything moved.
+func (s *Store) Rekey(from, to string) bool {
+	fromKey, toKey := CardKey(from), CardKey(to)
+	if fromKey == toKey {
+		return false
+	}
+	state, ok := s.Cards[fromKey]
+	if !ok {
+		return false
+	}
+	if _, taken := s.Cards[toKey]; taken {
+		return false
+	}
+	s.set(toKey, state)
+	s.remove(fromKey)
+	return true
+}
+
+// closestOrphan returns the orphan from the same file whose question is most
+// similar to the card's, preferring the same position on ties.
+func (s *Store) closestOrphan(c CardInfo, orphans map[string]bool) string {
+	best := ""
+	bestScore := 0.0
+	for key := range orphans {
+		state := s.Cards[key]
+		if state.SourceFile != c.SourceFile || state.Question == "" {
+			continue
+		}
+		score := similarity(state.Question, c.Question)
+		if score < minSimilarity {
+			continue
+		}
+		if state.Position == c.Position {
+			score += 0.01
+		}
+		if score > bestScore || score == bestScore && key < best {
+			best, bestScore = key, score
+		}
+	}
+	return best
+}
+
+// similarity returns 1 minus the edit distance between a and b relative to
+// the longer of the two, so 1 means identical and 0 means nothing in common.
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,176 @@
+package storage
+
+import "testing"
+
+func TestReconcile(t *testing.T) {
+	t.Run("records card metadata", func(t *testing.T) {
+		store := newTestStore()
+		store.Rate("What is Go?", Good)
+
+		store.Reconcile([]CardInfo{{Key: "What is Go?", Question: "What is Go?", SourceFile: "go.md", Position: 3}})
+
+		state := store.Cards[CardKey("What is Go?")]
+		if state.Question != "What is Go?" || state.SourceFile != "go.md" || state.Position != 3 {
+			t.Errorf("metadata = (%q, %q, %d)", state.Question, state.SourceFile, state.Position)
+		}
+	})
+
+	t.Run("typo fix keeps history", func(t *testing.T) {
+		store := newTestStore()
+		old := CardInfo{Key: "What is a gorutine?", Question: "What is a gorutine?", SourceFile: "go.md"}
+		store.Rate(old.Key, Good)
+		store.Reconcile([]CardInfo{old})
+		before := store.Cards[CardKey(old.Key)]
+
+		fixed := CardInfo{Key: "What is a goroutine?", Question: "What is a goroutine?", SourceFile: "go.md"}
+		if got := store.Reconcile([]CardInfo{fixed}); got != 1 {
+			t.Fatalf("migrated = %d, want 1", got)
+		}
+
+		if _, ok := store.Cards[CardKey(old.Key)]; ok {
+			t.Error("orphaned entry should be removed")
+		}
+		after, ok := store.Cards[CardKey(fixed.Key)]
+		if !ok {
+			t.Fatal("edited card has no state")
+		}
+		if !after.NextReview.Equal(before.NextReview) || after.Interval != before.Interval {
+			t.Error("schedule was not carried over")
+		}
+		if after.Question != fixed.Question {
+			t.Errorf("question = %q, want updated text", after.Question)
+		}
+	})
+
+	t.Run("different file is not matched", func(t *testing.T) {
+		store := newTestStore()
+		old := CardInfo{Key: "What is a gorutine?", Question: "What is a gorutine?", SourceFile: "go.md"}
+		store.Rate(old.Key, Good)
+		store.Reconcile([]CardInfo{old})
+
+		moved := CardInfo{Key: "What is a goroutine?", Question: "What is a goroutine?", SourceFile: "other.md"}
+		if got := store.Reconcile([]CardInfo{moved}); got != 0 {
+			t.Errorf("migrated = %d, want 0", got)
+		}
+	})
+
+	t.Run("rewritten question is a new card", func(t *testing.T) {
+		store := newTestStore()
+		old := CardInfo{Key: "What is Go?", Question: "What is Go?", SourceFile: "go.md"}
+		store.Rate(old.Key, Good)
+		store.Reconcile([]CardInfo{old})
+
+		other := CardInfo{Key: "Explain the select statement", Question: "Explain the select statement", SourceFile: "go.md"}
+		if got := store.Reconcile([]CardInfo{other}); got != 0 {
+			t.Errorf("migrated = %d, want 0", got)
+		}
+	})
+
+	t.Run("live cards are never taken", func(t *testing.T) {
+		store := newTestStore()
+		a := CardInfo{Key: "What is a map?", Question: "What is a map?", SourceFile: "go.md", Position: 0}
+		store.Rate(a.Key, Good)
+		store.Reconcile([]CardInfo{a})
+
+		b := CardInfo{Key: "What is a mop?", Question: "What is a mop?", SourceFile: "go.md", Position: 1}
+		if got := store.Reconcile([]CardInfo{a, b}); got != 0 {
+			t.Errorf("migrated = %d, want 0", got)
+		}
+		if !store.IsNew(b.Key) {
+			t.Error("new card should not inherit a live card's state")
+		}
+	})
+
+	t.Run("adding a stable ID keeps history", func(t *testing.T) {
+		store := newTestStore()
+		store.Rate("What is Go?", Easy)
+
+		withID := CardInfo{Key: "^go", Question: "What is Go?", SourceFile: "go.md"}
+		if got := store.Reconcile([]CardInfo{withID}); got != 1 {
+			t.Fatalf("migrated = %d, want 1", got)
+		}
+		if store.IsNew("^go") {
+			t.Error("card with new ID should keep its state")
+		}
+	})
+
+	t.Run("disambiguated duplicates each keep the shared history", func(t *testing.T) {
+		store := newTestStore()
+		store.Rate("What is a map?", Easy)
+		store.Rate("Unrelated", Good)
+		shared := store.GetState("What is a map?")
+		unrelated := store.GetState("Unrelated")
+
+		cards := []CardInfo{
+			{Key: "@deck:go\nWhat is a map?", Question: "What is a map?", SourceFile: "go.md"},
+			{Key: "@deck:rust\nWhat is a map?", Question: "What is a map?", SourceFile: "rust.md"},
+			{Key: "Unrelated", Question: "Unrelated", SourceFile: "go.md"},
+		}
+		if got := store.Reconcile(cards); got != 2 {
+			t.Fatalf("migrated = %d, want 2", got)
+		}
+
+		for _, c := range cards[:2] {
+			if got := store.GetState(c.Key); got.Interval != shared.Interval || !got.NextReview.Equal(shared.NextReview) {
+				t.Errorf("%q did not inherit the shared schedule", c.Key)
+			}
+		}
+		if _, ok := store.Cards[CardKey("What is a map?")]; ok {
+			t.Error("shared entry should be removed")
+		}
+		if got := store.GetState("Unrelated"); got.Interval != unrelated.Interval || !got.NextReview.Equal(unrelated.NextReview) {
+			t.Error("a card without duplicates lost its state")
+		}
+	})
+}
+
+func TestRekey(t *testing.T) {
+	t.Run("moves state to the new key", func(t *testing.T) {
+		store := newTestStore()
+		store.Rate("What does defer do?", Easy)
+		want := store.GetState("What does defer do?")
+
+		if !store.Rekey("What does defer do?", "When do deferred calls run?") {
+			t.Fatal("Rekey() = false, want true")
+		}
+		if got := store.GetState("When do deferred calls run?"); got != want {
+			t.Errorf("state = %+v, want %+v", got, want)
+		}
+		if !store.IsNew("What does defer do?") {
+			t.Error("old key still has state")
+		}
+	})
+
+	t.Run("keeps existing state at the new key", func(t *testing.T) {
+		store := newTestStore()
+		store.Rate("old", Easy)
+		store.Rate("new", Again)
+		want := store.GetState("new")
+
+		if store.Rekey("old", "new") {
+			t.Error("Rekey() = true, want false")
+		}
+		if got := store.GetState("new"); got != want {
+			t.Errorf("state = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("nothing to move", func(t *testing.T) {
+		store := newTestStore()
+		if store.Rekey("unknown", "new") {
+			t.Error("Rekey() = true, want false")
+		}
+	})
+}
+
+func TestSimilarity(t *testing.T) {
+	if got := similarity("abc", "abc"); got != 1 {
+		t.Errorf("identical = %f, want 1", got)
+	}
+	if got := similarity("kitten", "sitting"); got < 0.57 || got > 0.58 {
+		t.Errorf("kitten/sitting = %f, want ~0.571", got)
+	}
+	if got := similarity("", ""); got != 1 {
+		t.Errorf("empty = %f, want 1", got)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package storage
+
+// lockFile is a no-op where flock is unavailable; concurrent sessions still
+// merge on save but may interleave.
+func lockFile(string) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ReviewEvent is one rating, as appended to the review log.
+type ReviewEvent struct {
+	Time         time.Time     `json:"time"`
+	Key          string        `json:"key"`
+	Rating       Rating        `json:"rating"`
+	PrevInterval int           `json:"prev_interval"`
+	NewInterval  int           `json:"new_interval"`
+	Took         time.Duration `json:"took_ns,omitempty"` // time from showing the question to rating it
+}
+
+// LogPath returns the review log that sits next to the given state file.
+func LogPath(statePath string) string {
+	return filepath.Join(filepath.Dir(statePath), "reviews.jsonl")
+}
+
+// loadLog reads a JSON-lines review log. A missing file is an empty log.
+// A bad last line is a write torn by a crash and is skipped; anything bad
+// before it is an error.
+func loadLog(path string) ([]ReviewEvent, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var events []ReviewEvent
+	var torn error // the previous line's error, fatal unless it was the last
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if torn != nil {
+			return nil, torn
+		}
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var ev ReviewEvent
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			torn = fmt.Errorf("%s:%d: %w", path, line, err)
+			continue
+		}
+		events = append(events, ev)
+	}
+	return events, scanner.Err()
+}
+
+// trimTornLine cuts f back to its last newline, dropping a line left
+// half-written by a crash so the next append starts on a line of its own.
+func trimTornLine(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 4096)
+	for end := info.Size(); end > 0; {
+		start := max(end-int64(len(buf)), 0)
+		chunk := buf[:end-start]
+		if _, err := f.ReadAt(chunk, start); err != nil {
+			return err
+		}
+		if i := bytes.LastIndexByte(chunk, '\n'); i >= 0 {
+			if start+int64(i)+1 == info.Size() {
+				return nil // ends with a whole line
+			}
+			return f.Truncate(start + int64(i) + 1)
+		}
+		end = start
+	}
+	return f.Truncate(0)
+}
+
+// appendLog appends events to the review log, creating it if needed.
+func appendLog(path string, events []ReviewEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	if err := trimTornLine(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, ev := range events {
+		if err := enc.Encode(ev); err != nil {
+			_ = f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeLog replaces the review log with events.
+func writeLog(path string, events []ReviewEvent) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, ev := range events {
+		if err := enc.Encode(ev); err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(path, buf.Bytes())
+}
+
+// reviewQuerier is implemented by backends that keep the review log out of
+// memory and answer questions about it directly. Stats fall back to what is
+// in memory if a query fails.
+type reviewQuerier interface {
+	// reviewedSince returns the keys with a stored review at or after t.
+	reviewedSince(t time.Time) (map[string]bool, error)
+	// reviewedBetween reports whether there is a stored review in [from, to).
+	reviewedBetween(from, to time.Time) (bool, error)
+}
+
+// reviewedOn reports whether there was a review on the local day starting
+// at day, from days as returned by reviewDays or else from the backend.
+func (s *Store) reviewedOn(days map[string]bool, day time.Time) bool {
+	if days[day.Format("2006-01-02")] {
+		return true
+	}
+	q, ok := s.backend.(reviewQuerier)
+	if !ok {
+		return false
+	}
+	found, err := q.reviewedBetween(day, day.AddDate(0, 0, 1))
+	return err == nil && found
+}
+
+// reviewDays returns the set of local days ("2006-01-02") with at least one
+// review, from the log plus each card's LastReviewed so history from before
+// the log existed still counts.
+func (s *Store) reviewDays() map[string]bool {
+	days := make(map[string]bool)
+	for _, ev := range s.Log {
+		days[ev.Time.In(s.clock().Location()).Format("2006-01-02")] = true
+	}
+	for _, state := range s.Cards {
+		if !state.LastReviewed.IsZero() {
+			days[state.LastReviewed.In(s.clock().Location()).Format("2006-01-02")] = true
+		}
+	}
+	return days
+}
@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReviewLog(t *testing.T) {
+	t.Run("rate appends an event", func(t *testing.T) {
+		store := newTestStore()
+		store.Cards[CardKey("q")] = CardState{Interval: 4, EaseFactor: 2.5}
+
+		store.RateTimed("q", Good, 3*time.Second)
+
+		if len(store.Log) != 1 {
+			t.Fatalf("log has %d events, want 1", len(store.Log))
+		}
+		ev := store.Log[0]
+		if ev.Key != CardKey("q") || ev.Rating != Good || !ev.Time.Equal(testNow) {
+			t.Errorf("event = %+v", ev)
+		}
+		if ev.PrevInterval != 4 || ev.NewInterval != 10 {
+			t.Errorf("intervals = %d -> %d, want 4 -> 10", ev.PrevInterval, ev.NewInterval)
+		}
+		if ev.Took != 3*time.Second {
+			t.Errorf("took = %v, want 3s", ev.Took)
+		}
+	})
+
+	t.Run("save appends only new events", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "state.json")
+
+		store, err := Load(path)
+		if err != nil {
+			t.Fatalf("Load() error: %v", err)
+		}
+		store.Rate("a", Good)
+		store.Rate("b", Hard)
+		if err := store.Save(); err != nil {
+			t.Fatalf("Save() error: %v", err)
+		}
+		store.Rate("a", Easy)
+		if err := store.Save(); err != nil {
+			t.Fatalf("Save() error: %v", err)
+		}
+		if err := store.Save(); err != nil {
+			t.Fatalf("Save() error: %v", err)
+		}
+
+		reloaded, err := Load(path)
+		if err != nil {
+			t.Fatalf("Load() error: %v", err)
+		}
+		if len(reloaded.Log) != 3 {
+			t.Fatalf("reloaded log has %d events, want 3", len(reloaded.Log))
+		}
+		if reloaded.Log[2].Rating != Easy {
+			t.Errorf("last event rating = %d, want Easy", reloaded.Log[2].Rating)
+		}
+	})
+}
+
+func TestStreakFromLog(t *testing.T) {
+	store := newTestStore()
+	// The same card reviewed on three consecutive days only keeps the
+	// latest LastReviewed, but every review is in the log.
+	for _, daysAgo := range []int{2, 1, 0} {
+		at := testNow.AddDate(0, 0, -daysAgo)
+		store.SetClock(func() time.Time { return at })
+		store.Rate("daily", Good)
+	}
+	store.SetClock(func() time.Time { return testNow })
+
+	if got := store.Streak(); got != 3 {
+		t.Errorf("streak = %d, want 3", got)
+	}
+}
+
+func TestReviewedTodayFromLog(t *testing.T) {
+	store := newTestStore()
+	store.Log = []ReviewEvent{
+		{Time: testNow, Key: CardKey("q1")},
+		{Time: testNow.AddDate(0, 0, -1), Key: CardKey("q2")},
+	}
+
+	if got := store.ReviewedToday([]string{"q1", "q2", "q3"}); got != 1 {
+		t.Errorf("reviewed today = %d, want 1", got)
+	}
+}
+
+func TestLoadLogTornLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	store, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	store.Rate("a", Good)
+	store.Rate("b", Hard)
+	if err := store.Save(); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+
+	f, err := os.OpenFile(LogPath(path), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"time":"2026-`); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	store, err = Load(path)
+	if err != nil {
+		t.Fatalf("Load() with a torn last line: %v", err)
+	}
+	if len(store.Log) != 2 {
+		t.Fatalf("log has %d events, want 2", len(store.Log))
+	}
+
+	// The next save must not append onto the torn line.
+	store.Rate("c", Easy)
+	if err := store.Save(); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() after saving past a torn line: %v", err)
+	}
+	if len(reloaded.Log) != 3 {
+		t.Errorf("log has %d events, want 3", len(reloaded.Log))
+	}
+}
+
+func TestLoadLogCorruptLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reviews.jsonl")
+	if err := os.WriteFile(path, []byte("{\"key\":\"a\"}\nnot json\n{\"key\":\"b\"}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := loadLog(path); err == nil || !strings.Contains(err.Error(), ":2:") {
+		t.Errorf("loadLog() error = %v, want one for line 2", err)
+	}
+}
@@ -0,0 +1,107 @@
+package storage
+
+import "time"
+
+// Scheduler decides when a card should next be reviewed.
+// Implementations are pure: all time comes in through now.
+type Scheduler interface {
+	// Rate returns the card state after answering with the given rating.
+	Rate(state CardState, rating Rating, now time.Time) CardState
+	// IsDue reports whether the card should be shown at now.
+	IsDue(state CardState, now time.Time) bool
+	// Preview returns the interval in days each rating would produce.
+	Preview(state CardState, now time.Time) map[Rating]int
+}
+
+// Ratings lists every rating in button order.
+var Ratings = []Rating{Again, Hard, Good, Easy}
+
+// previewIntervals rates a copy of state with every rating and collects the resulting intervals.
+// Again is reported as 0 days since it re-queues the card right away.
+func previewIntervals(sched Scheduler, state CardState, now time.Time) map[Rating]int {
+	intervals := make(map[Rating]int, len(Ratings))
+	for _, r := range Ratings {
+		next := sched.Rate(state, r, now)
+		if next.NextReview.After(now) {
+			intervals[r] = next.Interval
+		} else {
+			intervals[r] = 0
+		}
+	}
+	return intervals
+}
+
+// SM2Scheduler is a simplified SM-2: the interval grows by the ease factor,
+// and ease moves up or down with Easy and Hard answers.
+type SM2Scheduler struct{}
+
+func (SM2Scheduler) IsDue(state CardState, now time.Time) bool {
+	return !now.Before(state.NextReview)
+}
+
+func (sm SM2Scheduler) Preview(state CardState, now time.Time) map[Rating]int {
+	return previewIntervals(sm, state, now)
+}
+
+func (SM2Scheduler) Rate(state CardState, rating Rating, now time.Time) CardState {
+	if rating == Again {
+		// A learned card that is forgotten counts as a lapse; repeated
+		// failures while relearning don't count again.
+		if state.Interval > 0 && !state.Relearning {
+			state.Lapses++
+		}
+		state.Interval = 1
+		state.EaseFactor -= 0.2
+		if state.EaseFactor < 1.3 {
+			state.EaseFactor = 1.3
+		}
+		state.Relearning = true
+		// due again right away so it is re-shown in this or the next session
+		state.NextReview = now
+		state.LastReviewed = now
+		return state
+	}
+
+	if state.Interval == 0 {
+		// New card
+		state.Interval = 1
+		state.EaseFactor = 2.5
+	}
+
+	if state.Relearning && rating != Easy {
+		// Passing a relearning card graduates it back to review at 1 day
+		// without touching ease; Easy skips ahead as usual.
+		state.Relearning = false
+		state.Interval = 1
+		state.NextReview = now.Add(24 * time.Hour)
+		state.LastReviewed = now
+		return state
+	}
+	state.Relearning = false
+
+	switch rating {
+	case Hard:
+		// interval stays same, ease decreases
+		state.EaseFactor -= 0.15
+		if state.EaseFactor < 1.3 {
+			state.EaseFactor = 1.3
+		}
+	case Good:
+		// interval *= ease factor
+		state.Interval = int(float64(state.Interval) * state.EaseFactor)
+		if state.Interval < 1 {
+			state.Interval = 1
+		}
+	case Easy:
+		// interval *= ease * 1.3, ease increases
+		state.Interval = int(float64(state.Interval) * state.EaseFactor * 1.3)
+		if state.Interval < 1 {
+			state.Interval = 1
+		}
+		state.EaseFactor += 0.15
+	}
+
+	state.NextReview = now.Add(time.Duration(state.Interval) * 24 * time.Hour)
+	state.LastReviewed = now
+	return state
+}
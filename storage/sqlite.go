@@ -0,0 +1,292 @@
+package storage
+
+import (
+	"database/sql"
+	"maps"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite" // pure-Go driver, registers "sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS cards (
+	key           TEXT PRIMARY KEY,
+	next_review   TEXT NOT NULL,
+	interval      INTEGER NOT NULL,
+	ease_factor   REAL NOT NULL,
+	last_reviewed TEXT NOT NULL,
+	lapses        INTEGER NOT NULL DEFAULT 0,
+	relearning    INTEGER NOT NULL DEFAULT 0,
+	stability     REAL NOT NULL DEFAULT 0,
+	difficulty    REAL NOT NULL DEFAULT 0,
+	question      TEXT NOT NULL DEFAULT '',
+	source_file   TEXT NOT NULL DEFAULT '',
+	position      INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS reviews (
+	id            INTEGER PRIMARY KEY AUTOINCREMENT,
+	time          TEXT NOT NULL,
+	key           TEXT NOT NULL,
+	rating        INTEGER NOT NULL,
+	prev_interval INTEGER NOT NULL,
+	new_interval  INTEGER NOT NULL,
+	took_ns       INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS reviews_key ON reviews(key);
+CREATE INDEX IF NOT EXISTS reviews_time ON reviews(time);
+`
+
+// DefaultDBPath returns the SQLite database path, next to DefaultPath.
+func DefaultDBPath() string {
+	return filepath.Join(filepath.Dir(DefaultPath()), "state.db")
+}
+
+// sqliteBackend stores cards and reviews in a SQLite database, writing only
+// changed rows on save.
+type sqliteBackend struct {
+	db *sql.DB
+}
+
+// OpenSQLite opens (creating if needed) the SQLite database at path. A new
+// database is seeded from jsonPath and its review log if they exist; the
+// JSON files are left in place as a backup.
+func OpenSQLite(path, jsonPath string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	// WAL lets `due` read while a review session writes; the busy timeout
+	// makes concurrent writers wait for each other instead of failing.
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	// a single connection serialises writers within the process
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	b := &sqliteBackend{db: db}
+	if err := b.migrateJSON(jsonPath); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return Open(b)
+}
+
+// migrateJSON imports the JSON state and review log into an empty database.
+func (b *sqliteBackend) migrateJSON(jsonPath string) error {
+	var n int
+	if err := b.db.QueryRow(`SELECT (SELECT COUNT(*) FROM cards) + (SELECT COUNT(*) FROM reviews)`).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 || jsonPath == "" {
+		return nil
+	}
+
+	cards, log, err := (&jsonBackend{path: jsonPath}).Load()
+	if err != nil {
+		return err
+	}
+	if len(cards) == 0 && len(log) == 0 {
+		return nil
+	}
+
+	changed := make([]string, 0, len(cards))
+	for key := range cards {
+		changed = append(changed, key)
+	}
+	return b.Save(cards, changed, log)
+}
+
+// Load returns the cards only: the review history stays in the database,
+// where reviewedSince and reviewedBetween query it.
+func (b *sqliteBackend) Load() (map[string]CardState, []ReviewEvent, error) {
+	cards, err := queryCards(b.db)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cards, nil, nil
+}
+
+func (b *sqliteBackend) reviewedSince(t time.Time) (map[string]bool, error) {
+	rows, err := b.db.Query(`SELECT DISTINCT key FROM reviews WHERE time >= ?`, formatTime(t))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	keys := make(map[string]bool)
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys[key] = true
+	}
+	return keys, rows.Err()
+}
+
+func (b *sqliteBackend) reviewedBetween(from, to time.Time) (bool, error) {
+	var found bool
+	err := b.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM reviews WHERE time >= ? AND time < ?)`,
+		formatTime(from), formatTime(to)).Scan(&found)
+	return found, err
+}
+
+// querier is what queryCards needs from a database or transaction.
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// queryCards reads every card row.
+func queryCards(q querier) (map[string]CardState, error) {
+	rows, err := q.Query(`SELECT key, next_review, interval, ease_factor, last_reviewed,
+		lapses, relearning, stability, difficulty, question, source_file, position FROM cards`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	cards := make(map[string]CardState)
+	for rows.Next() {
+		var key, next, last string
+		var st CardState
+		if err := rows.Scan(&key, &next, &st.Interval, &st.EaseFactor, &last,
+			&st.Lapses, &st.Relearning, &st.Stability, &st.Difficulty,
+			&st.Question, &st.SourceFile, &st.Position); err != nil {
+			return nil, err
+		}
+		if st.NextReview, err = parseTime(next); err != nil {
+			return nil, err
+		}
+		if st.LastReviewed, err = parseTime(last); err != nil {
+			return nil, err
+		}
+		cards[key] = st
+	}
+	return cards, rows.Err()
+}
+
+// Save writes the changed rows, then reads all rows back into cards, so
+// changes other processes made show up here too. A changed row keeps
+// whichever review is newer, as the upsert decided.
+func (b *sqliteBackend) Save(cards map[string]CardState, changed []string, events []ReviewEvent) error {
+	return b.write(cards, changed, events, true)
+}
+
+// Commit writes only the changed rows, without reading anything back.
+func (b *sqliteBackend) Commit(cards map[string]CardState, changed []string, events []ReviewEvent) error {
+	return b.write(cards, changed, events, false)
+}
+
+func (b *sqliteBackend) write(cards map[string]CardState, changed []string, events []ReviewEvent, readBack bool) error {
+	tx, err := b.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// A row reviewed more recently by another process is left alone.
+	upsert, err := tx.Prepare(`INSERT INTO cards (key, next_review, interval, ease_factor,
+		last_reviewed, lapses, relearning, stability, difficulty, question, source_file, position)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET next_review = excluded.next_review, interval = excluded.interval,
+			ease_factor = excluded.ease_factor, last_reviewed = excluded.last_reviewed,
+			lapses = excluded.lapses, relearning = excluded.relearning, stability = excluded.stability,
+			difficulty = excluded.difficulty, question = excluded.question,
+			source_file = excluded.source_file, position = excluded.position
+		WHERE excluded.last_reviewed >= cards.last_reviewed`)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = upsert.Close() }()
+
+	for _, key := range changed {
+		st, ok := cards[key]
+		if !ok {
+			if _, err := tx.Exec(`DELETE FROM cards WHERE key = ?`, key); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := upsert.Exec(key, formatTime(st.NextReview), st.Interval, st.EaseFactor,
+			formatTime(st.LastReviewed), st.Lapses, st.Relearning, st.Stability, st.Difficulty,
+			st.Question, st.SourceFile, st.Position); err != nil {
+			return err
+		}
+	}
+
+	for _, ev := range events {
+		if _, err := tx.Exec(`INSERT INTO reviews (time, key, rating, prev_interval, new_interval, took_ns)
+			VALUES (?, ?, ?, ?, ?, ?)`, formatTime(ev.Time), ev.Key, ev.Rating,
+			ev.PrevInterval, ev.NewInterval, int64(ev.Took)); err != nil {
+			return err
+		}
+	}
+
+	if !readBack {
+		return tx.Commit()
+	}
+	disk, err := queryCards(tx)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	clear(cards)
+	maps.Copy(cards, disk)
+	return nil
+}
+
+// Undo replaces the card's row outright, even though the rating being
+// taken back left a later last_reviewed, and deletes the rating's review.
+func (b *sqliteBackend) Undo(key string, state *CardState, event ReviewEvent) error {
+	tx, err := b.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if state == nil {
+		_, err = tx.Exec(`DELETE FROM cards WHERE key = ?`, key)
+	} else {
+		_, err = tx.Exec(`INSERT OR REPLACE INTO cards (key, next_review, interval, ease_factor,
+			last_reviewed, lapses, relearning, stability, difficulty, question, source_file, position)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			key, formatTime(state.NextReview), state.Interval, state.EaseFactor,
+			formatTime(state.LastReviewed), state.Lapses, state.Relearning, state.Stability, state.Difficulty,
+			state.Question, state.SourceFile, state.Position)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM reviews WHERE id = (SELECT MAX(id) FROM reviews WHERE key = ? AND time = ?)`,
+		event.Key, formatTime(event.Time)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (b *sqliteBackend) Close() error {
+	return b.db.Close()
+}
+
+// sqliteTimeFormat is fixed-width UTC RFC 3339, so stored times stay
+// readable and compare correctly as text.
+const sqliteTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeFormat)
+}
+
+func parseTime(s string) (time.Time, error) {
+	return time.Parse(time.RFC3339Nano, s)
+}
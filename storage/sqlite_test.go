@@ -0,0 +1,243 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSQLiteRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "state.db")
+
+	store, err := OpenSQLite(dbPath, "")
+	if err != nil {
+		t.Fatalf("OpenSQLite() error: %v", err)
+	}
+	store.SetClock(func() time.Time { return testNow })
+	store.RateTimed("What is Go?", Good, 2*time.Second)
+	store.Rate("What is Rust?", Again)
+	if err := store.Save(); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	reloaded, err := OpenSQLite(dbPath, "")
+	if err != nil {
+		t.Fatalf("reopen error: %v", err)
+	}
+	defer func() { _ = reloaded.Close() }()
+
+	want := store.Cards[CardKey("What is Go?")]
+	got, ok := reloaded.Cards[CardKey("What is Go?")]
+	if !ok {
+		t.Fatal("card not found after reload")
+	}
+	if !got.NextReview.Equal(want.NextReview) || got.Interval != want.Interval || got.EaseFactor != want.EaseFactor {
+		t.Errorf("reloaded state = %+v, want %+v", got, want)
+	}
+	if !reloaded.Cards[CardKey("What is Rust?")].Relearning {
+		t.Error("relearning flag lost")
+	}
+	if len(reloaded.Log) != 0 {
+		t.Errorf("Load read %d reviews, want the history left in the database", len(reloaded.Log))
+	}
+	log := storedReviews(t, reloaded)
+	if len(log) != 2 {
+		t.Fatalf("log has %d events, want 2", len(log))
+	}
+	if log[0].Took != 2*time.Second || log[1].Rating != Again {
+		t.Errorf("log = %+v", log)
+	}
+}
+
+// storedReviews returns every review in the store's database, oldest first.
+func storedReviews(t *testing.T, store *Store) []ReviewEvent {
+	t.Helper()
+	rows, err := store.Backend().(*sqliteBackend).db.Query(
+		`SELECT time, key, rating, prev_interval, new_interval, took_ns FROM reviews ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var log []ReviewEvent
+	for rows.Next() {
+		var at string
+		var ev ReviewEvent
+		if err := rows.Scan(&at, &ev.Key, &ev.Rating, &ev.PrevInterval, &ev.NewInterval, &ev.Took); err != nil {
+			t.Fatal(err)
+		}
+		if ev.Time, err = parseTime(at); err != nil {
+			t.Fatal(err)
+		}
+		log = append(log, ev)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return log
+}
+
+func TestSQLiteUndo(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "state.db")
+
+	store, err := OpenSQLite(dbPath, "")
+	if err != nil {
+		t.Fatalf("OpenSQLite() error: %v", err)
+	}
+	store.Rate("What is Go?", Good)
+	before := store.GetState("What is Go?")
+	store.Rate("What is Go?", Again)
+	if err := store.Commit(); err != nil {
+		t.Fatalf("Commit() error: %v", err)
+	}
+	if _, err := store.Undo(); err != nil {
+		t.Fatalf("Undo() error: %v", err)
+	}
+	_ = store.Close()
+
+	reloaded, err := OpenSQLite(dbPath, "")
+	if err != nil {
+		t.Fatalf("reopen error: %v", err)
+	}
+	defer func() { _ = reloaded.Close() }()
+
+	got := reloaded.GetState("What is Go?")
+	if got.Interval != before.Interval || got.Relearning {
+		t.Errorf("state after undo = %+v, want %+v", got, before)
+	}
+	if log := storedReviews(t, reloaded); len(log) != 1 || log[0].Rating != Good {
+		t.Errorf("log = %+v, want only the Good rating", log)
+	}
+}
+
+func TestSQLiteSaveDeletesRemovedCards(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "state.db")
+
+	store, err := OpenSQLite(dbPath, "")
+	if err != nil {
+		t.Fatalf("OpenSQLite() error: %v", err)
+	}
+	store.Rate("What is a gorutine?", Good)
+	store.Reconcile([]CardInfo{{Key: "What is a gorutine?", Question: "What is a gorutine?", SourceFile: "go.md"}})
+	store.Reconcile([]CardInfo{{Key: "What is a goroutine?", Question: "What is a goroutine?", SourceFile: "go.md"}})
+	if err := store.Save(); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+	_ = store.Close()
+
+	reloaded, err := OpenSQLite(dbPath, "")
+	if err != nil {
+		t.Fatalf("reopen error: %v", err)
+	}
+	defer func() { _ = reloaded.Close() }()
+
+	if len(reloaded.Cards) != 1 {
+		t.Errorf("got %d cards, want 1", len(reloaded.Cards))
+	}
+	if reloaded.IsNew("What is a goroutine?") {
+		t.Error("migrated card missing")
+	}
+}
+
+func TestSQLiteSaveMergesOtherStores(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "state.db")
+
+	a, err := OpenSQLite(dbPath, "")
+	if err != nil {
+		t.Fatalf("OpenSQLite() error: %v", err)
+	}
+	defer func() { _ = a.Close() }()
+	b, err := OpenSQLite(dbPath, "")
+	if err != nil {
+		t.Fatalf("OpenSQLite() error: %v", err)
+	}
+	defer func() { _ = b.Close() }()
+
+	a.Rate("only a", Good)
+	b.Rate("only b", Good)
+	if err := a.Save(); err != nil {
+		t.Fatalf("a.Save() error: %v", err)
+	}
+	if err := b.Save(); err != nil {
+		t.Fatalf("b.Save() error: %v", err)
+	}
+
+	if b.IsNew("only a") || b.IsNew("only b") {
+		t.Error("Save should merge the other session's cards into memory")
+	}
+}
+
+func TestSQLiteMigratesJSON(t *testing.T) {
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "state.json")
+
+	js, err := Load(jsonPath)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	js.Rate("q1", Good)
+	js.Rate("q2", Easy)
+	if err := js.Save(); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+
+	dbPath := filepath.Join(dir, "state.db")
+	store, err := OpenSQLite(dbPath, jsonPath)
+	if err != nil {
+		t.Fatalf("OpenSQLite() error: %v", err)
+	}
+	if n := len(storedReviews(t, store)); len(store.Cards) != 2 || n != 2 {
+		t.Errorf("migrated %d cards and %d events, want 2 and 2", len(store.Cards), n)
+	}
+	_ = store.Close()
+
+	// A second open must not import the JSON state again.
+	store, err = OpenSQLite(dbPath, jsonPath)
+	if err != nil {
+		t.Fatalf("reopen error: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+	if n := len(storedReviews(t, store)); n != 2 {
+		t.Errorf("log has %d events after reopen, want 2", n)
+	}
+}
+
+func TestSQLiteStatsFromDatabase(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "state.db")
+
+	store, err := OpenSQLite(dbPath, "")
+	if err != nil {
+		t.Fatalf("OpenSQLite() error: %v", err)
+	}
+	// The same card on three days in a row: only the last review is left
+	// in its state, so the earlier days come from the reviews table.
+	for _, daysAgo := range []int{2, 1, 0} {
+		at := testNow.AddDate(0, 0, -daysAgo)
+		store.SetClock(func() time.Time { return at })
+		store.Rate("daily", Good)
+	}
+	store.SetClock(func() time.Time { return testNow.AddDate(0, 0, -1) })
+	store.Rate("yesterday", Good)
+	if err := store.Save(); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+	_ = store.Close()
+
+	reloaded, err := OpenSQLite(dbPath, "")
+	if err != nil {
+		t.Fatalf("reopen error: %v", err)
+	}
+	defer func() { _ = reloaded.Close() }()
+	reloaded.SetClock(func() time.Time { return testNow })
+
+	if got := reloaded.Streak(); got != 3 {
+		t.Errorf("streak = %d, want 3", got)
+	}
+	if got := reloaded.ReviewedToday([]string{"daily", "yesterday"}); got != 1 {
+		t.Errorf("reviewed today = %d, want 1", got)
+	}
+}
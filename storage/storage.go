@@ -2,10 +2,11 @@ package storage
 
 import (
 	"crypto/sha256"
-	"encoding/json"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 )
 
@@ -14,21 +15,49 @@ type CardState struct {
 	Interval     int       `json:"interval"` // days
 	EaseFactor   float64   `json:"ease_factor"`
 	LastReviewed time.Time `json:"last_reviewed,omitempty"`
+	Lapses       int       `json:"lapses,omitempty"`     // times the card was forgotten after being learned
+	Relearning   bool      `json:"relearning,omitempty"` // true after Again until the card is passed
+	Stability    float64   `json:"stability,omitempty"`  // FSRS: days until recall drops to 90%
+	Difficulty   float64   `json:"difficulty,omitempty"` // FSRS: 1 (easy) .. 10 (hard)
+
+	// Where the card was last seen, used by Reconcile to follow edits.
+	Question   string `json:"question,omitempty"`
+	SourceFile string `json:"source_file,omitempty"`
+	Position   int    `json:"position,omitempty"`
 }
 
 type Rating int
 
 const (
-	Hard Rating = iota
+	Again Rating = iota
+	Hard
 	Good
 	Easy
 )
 
 type Store struct {
-	Cards map[string]CardState `json:"cards"`
-	path  string
+	Cards     map[string]CardState `json:"cards"`
+	Log       []ReviewEvent        `json:"-"` // ratings loaded from the backend and made since, oldest first
+	logged    int                  // how many Log entries are already on disk
+	dirty     map[string]bool      // keys changed or removed since the last Save
+	backend   Backend
+	path      string // state file for stores built without a backend
+	scheduler Scheduler
+	overrides map[string]Scheduler // per-card schedulers, by CardKey
+	undo      []undoEntry          // ratings made through this store, oldest first
+	now       func() time.Time
 }
 
+// undoEntry is what Undo needs to take back a rating.
+type undoEntry struct {
+	key     string
+	prev    CardState
+	existed bool // false if the card was new
+	event   ReviewEvent
+}
+
+// CardKey hashes a card's identity: its question text, or "^id" for cards
+// with a stable ID marker (see parser.Card.Key).
 func CardKey(question string) string {
 	h := sha256.Sum256([]byte(question))
 	return fmt.Sprintf("%x", h[:16])
@@ -43,39 +72,133 @@ func DefaultPath() string {
 	return filepath.Join(dataDir, "ankies-franc", "state.json")
 }
 
+// Load opens the JSON state file at path, with the review log next to it.
 func Load(path string) (*Store, error) {
-	s := &Store{
-		Cards: make(map[string]CardState),
-		path:  path,
-	}
+	return Open(&jsonBackend{path: path})
+}
 
-	data, err := os.ReadFile(path)
+// Open loads all card state and the review log from a backend.
+func Open(backend Backend) (*Store, error) {
+	cards, log, err := backend.Load()
 	if err != nil {
-		if os.IsNotExist(err) {
-			return s, nil
-		}
 		return nil, err
 	}
-
-	if err := json.Unmarshal(data, &s.Cards); err != nil {
-		return nil, err
+	if cards == nil {
+		cards = make(map[string]CardState)
 	}
-
-	return s, nil
+	return &Store{
+		Cards:   cards,
+		Log:     log,
+		logged:  len(log),
+		backend: backend,
+	}, nil
 }
 
+// Save writes changed card state and new review log entries to the backend.
 func (s *Store) Save() error {
-	dir := filepath.Dir(s.path)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return err
+	return s.flush(s.Backend().Save)
+}
+
+// Commit durably records the ratings made since the last Commit or Save
+// without rewriting all state, so a crash mid-session loses nothing.
+func (s *Store) Commit() error {
+	return s.flush(s.Backend().Commit)
+}
+
+func (s *Store) flush(write func(map[string]CardState, []string, []ReviewEvent) error) error {
+	changed := make([]string, 0, len(s.dirty))
+	for key := range s.dirty {
+		changed = append(changed, key)
 	}
+	sort.Strings(changed)
 
-	data, err := json.MarshalIndent(s.Cards, "", "  ")
-	if err != nil {
+	if err := write(s.Cards, changed, s.Log[s.logged:]); err != nil {
 		return err
 	}
+	s.logged = len(s.Log)
+	s.dirty = nil
+	return nil
+}
+
+// Close releases the backend.
+func (s *Store) Close() error {
+	return s.Backend().Close()
+}
+
+// Backend returns where the store persists, the JSON file at its path by default.
+func (s *Store) Backend() Backend {
+	if s.backend == nil {
+		s.backend = &jsonBackend{path: s.path}
+	}
+	return s.backend
+}
+
+// set stores a card's state and marks it for the next Save.
+func (s *Store) set(key string, state CardState) {
+	s.Cards[key] = state
+	s.markDirty(key)
+}
+
+// remove deletes a card's state and marks it for the next Save.
+func (s *Store) remove(key string) {
+	delete(s.Cards, key)
+	s.markDirty(key)
+}
+
+func (s *Store) markDirty(key string) {
+	if s.dirty == nil {
+		s.dirty = make(map[string]bool)
+	}
+	s.dirty[key] = true
+}
+
+// Scheduler returns the scheduler used by Rate and IsDue, SM-2 unless set otherwise.
+func (s *Store) Scheduler() Scheduler {
+	if s.scheduler == nil {
+		return SM2Scheduler{}
+	}
+	return s.scheduler
+}
+
+// SetScheduler replaces the scheduling algorithm.
+func (s *Store) SetScheduler(sched Scheduler) {
+	s.scheduler = sched
+}
+
+// SetCardScheduler schedules one card with sched instead of the store-wide
+// scheduler. Switching a card to FSRS seeds its memory state from SM-2.
+func (s *Store) SetCardScheduler(question string, sched Scheduler) {
+	key := CardKey(question)
+	if s.overrides == nil {
+		s.overrides = make(map[string]Scheduler)
+	}
+	s.overrides[key] = sched
+
+	if _, ok := sched.(FSRSScheduler); ok {
+		if state, ok := s.Cards[key]; ok && state.Stability == 0 && state.Interval > 0 {
+			s.set(key, migrateSM2(state))
+		}
+	}
+}
+
+// schedulerFor returns the scheduler for the card with the given key.
+func (s *Store) schedulerFor(key string) Scheduler {
+	if sched, ok := s.overrides[key]; ok {
+		return sched
+	}
+	return s.Scheduler()
+}
+
+// SetClock overrides the time source, so tests can pin "now".
+func (s *Store) SetClock(now func() time.Time) {
+	s.now = now
+}
 
-	return os.WriteFile(s.path, data, 0644)
+func (s *Store) clock() time.Time {
+	if s.now == nil {
+		return time.Now()
+	}
+	return s.now()
 }
 
 func (s *Store) GetState(question string) CardState {
@@ -92,46 +215,72 @@ func (s *Store) GetState(question string) CardState {
 }
 
 func (s *Store) IsDue(question string) bool {
-	state := s.GetState(question)
-	return !time.Now().Before(state.NextReview)
+	return s.schedulerFor(CardKey(question)).IsDue(s.GetState(question), s.clock())
 }
 
 func (s *Store) Rate(question string, rating Rating) {
+	s.RateTimed(question, rating, 0)
+}
+
+// RateTimed rates a card like Rate and records how long the answer took in the review log.
+func (s *Store) RateTimed(question string, rating Rating, took time.Duration) {
 	key := CardKey(question)
-	state := s.GetState(question)
+	now := s.clock()
+	_, existed := s.Cards[key]
+	prev := s.GetState(question)
+	next := s.schedulerFor(key).Rate(prev, rating, now)
+	s.set(key, next)
+	event := ReviewEvent{
+		Time:         now,
+		Key:          key,
+		Rating:       rating,
+		PrevInterval: prev.Interval,
+		NewInterval:  next.Interval,
+		Took:         took,
+	}
+	s.Log = append(s.Log, event)
+	s.undo = append(s.undo, undoEntry{key: key, prev: prev, existed: existed, event: event})
+}
 
-	if state.Interval == 0 {
-		// New card
-		state.Interval = 1
-		state.EaseFactor = 2.5
+// Undo takes back the last rating made through this store that is not
+// undone yet: the card gets its earlier state back and the rating leaves
+// the review log. A rating already committed or saved is taken back on
+// disk right away. It reports false if there is nothing to undo.
+func (s *Store) Undo() (bool, error) {
+	if len(s.undo) == 0 {
+		return false, nil
 	}
+	u := s.undo[len(s.undo)-1]
+	last := len(s.Log) - 1 // only ratings append to the log, so this is u.event
 
-	switch rating {
-	case Hard:
-		// interval stays same, ease decreases
-		state.EaseFactor -= 0.15
-		if state.EaseFactor < 1.3 {
-			state.EaseFactor = 1.3
+	if last < s.logged {
+		var prev *CardState
+		if u.existed {
+			prev = &u.prev
 		}
-	case Good:
-		// interval *= ease factor
-		state.Interval = int(float64(state.Interval) * state.EaseFactor)
-		if state.Interval < 1 {
-			state.Interval = 1
+		if err := s.Backend().Undo(u.key, prev, u.event); err != nil {
+			return false, err
 		}
-	case Easy:
-		// interval *= ease * 1.3, ease increases
-		state.Interval = int(float64(state.Interval) * state.EaseFactor * 1.3)
-		if state.Interval < 1 {
-			state.Interval = 1
+		s.logged--
+		if u.existed {
+			s.Cards[u.key] = u.prev
+		} else {
+			delete(s.Cards, u.key)
 		}
-		state.EaseFactor += 0.15
+	} else if u.existed {
+		s.set(u.key, u.prev)
+	} else {
+		s.remove(u.key)
 	}
 
-	now := time.Now()
-	state.NextReview = now.Add(time.Duration(state.Interval) * 24 * time.Hour)
-	state.LastReviewed = now
-	s.Cards[key] = state
+	s.Log = s.Log[:last]
+	s.undo = s.undo[:len(s.undo)-1]
+	return true, nil
+}
+
+// Preview returns the interval in days each rating would give the card right now.
+func (s *Store) Preview(question string) map[Rating]int {
+	return s.schedulerFor(CardKey(question)).Preview(s.GetState(question), s.clock())
 }
 
 func (s *Store) DueCount(questions []string) int {
@@ -158,59 +307,61 @@ func (s *Store) IsOverdue(question string) bool {
 	if !ok {
 		return false // new cards aren't overdue
 	}
-	return time.Now().After(state.NextReview.Add(24 * time.Hour))
+	return s.clock().After(state.NextReview.Add(24 * time.Hour))
 }
 
 // ReviewedToday returns how many of the given questions were reviewed today.
 func (s *Store) ReviewedToday(questions []string) int {
-	now := time.Now()
+	now := s.clock()
 	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
 
+	today := make(map[string]bool)
+	for _, ev := range s.Log {
+		if !ev.Time.Before(startOfDay) {
+			today[ev.Key] = true
+		}
+	}
+	if q, ok := s.backend.(reviewQuerier); ok {
+		if keys, err := q.reviewedSince(startOfDay); err == nil {
+			maps.Copy(today, keys)
+		}
+	}
+
 	count := 0
 	for _, q := range questions {
 		key := CardKey(q)
 		state, ok := s.Cards[key]
-		if ok && !state.LastReviewed.Before(startOfDay) {
+		if today[key] || ok && !state.LastReviewed.Before(startOfDay) {
 			count++
 		}
 	}
 	return count
 }
 
-// Streak returns the number of consecutive days (including today) with at least one review.
+// Streak returns the number of consecutive days (including today) with at least one review,
+// counted from the review log.
 func (s *Store) Streak() int {
-	if len(s.Cards) == 0 {
-		return 0
-	}
-
-	// Collect unique review dates
-	reviewDays := make(map[string]bool)
-	for _, state := range s.Cards {
-		if !state.LastReviewed.IsZero() {
-			day := state.LastReviewed.Format("2006-01-02")
-			reviewDays[day] = true
-		}
-	}
-
-	if len(reviewDays) == 0 {
+	reviewDays := s.reviewDays()
+	_, querier := s.backend.(reviewQuerier)
+	if len(reviewDays) == 0 && !querier {
 		return 0
 	}
 
-	now := time.Now()
+	now := s.clock()
 	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
 
 	// Check if today has reviews; if not, start from yesterday
 	streak := 0
 	day := today
-	if !reviewDays[day.Format("2006-01-02")] {
+	if !s.reviewedOn(reviewDays, day) {
 		// No review today, check if yesterday had one (streak from yesterday)
 		day = day.AddDate(0, 0, -1)
-		if !reviewDays[day.Format("2006-01-02")] {
+		if !s.reviewedOn(reviewDays, day) {
 			return 0
 		}
 	}
 
-	for reviewDays[day.Format("2006-01-02")] {
+	for s.reviewedOn(reviewDays, day) {
 		streak++
 		day = day.AddDate(0, 0, -1)
 	}
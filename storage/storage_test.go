@@ -7,6 +7,15 @@ import (
 	"time"
 )
 
+// testNow is the fixed time seen by stores built with newTestStore.
+var testNow = time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+func newTestStore() *Store {
+	store := &Store{Cards: make(map[string]CardState)}
+	store.SetClock(func() time.Time { return testNow })
+	return store
+}
+
 func TestCardKey(t *testing.T) {
 	t.Run("deterministic", func(t *testing.T) {
 		key1 := CardKey("What is Go?")
@@ -70,7 +79,7 @@ func TestLoadSave(t *testing.T) {
 
 func TestGetState(t *testing.T) {
 	t.Run("new card returns defaults", func(t *testing.T) {
-		store := &Store{Cards: make(map[string]CardState)}
+		store := newTestStore()
 		state := store.GetState("new question")
 
 		if state.EaseFactor != 2.5 {
@@ -85,12 +94,12 @@ func TestGetState(t *testing.T) {
 	})
 
 	t.Run("existing card returns stored state", func(t *testing.T) {
-		store := &Store{Cards: make(map[string]CardState)}
+		store := newTestStore()
 		key := CardKey("existing question")
 		store.Cards[key] = CardState{
 			EaseFactor: 3.0,
 			Interval:   5,
-			NextReview: time.Now().Add(24 * time.Hour),
+			NextReview: testNow.Add(24 * time.Hour),
 		}
 
 		state := store.GetState("existing question")
@@ -105,17 +114,17 @@ func TestGetState(t *testing.T) {
 
 func TestIsDue(t *testing.T) {
 	t.Run("new card is due", func(t *testing.T) {
-		store := &Store{Cards: make(map[string]CardState)}
+		store := newTestStore()
 		if !store.IsDue("new question") {
 			t.Error("new card should be due")
 		}
 	})
 
 	t.Run("future card is not due", func(t *testing.T) {
-		store := &Store{Cards: make(map[string]CardState)}
+		store := newTestStore()
 		key := CardKey("future question")
 		store.Cards[key] = CardState{
-			NextReview: time.Now().Add(48 * time.Hour),
+			NextReview: testNow.Add(48 * time.Hour),
 			EaseFactor: 2.5,
 		}
 		if store.IsDue("future question") {
@@ -124,10 +133,10 @@ func TestIsDue(t *testing.T) {
 	})
 
 	t.Run("past card is due", func(t *testing.T) {
-		store := &Store{Cards: make(map[string]CardState)}
+		store := newTestStore()
 		key := CardKey("past question")
 		store.Cards[key] = CardState{
-			NextReview: time.Now().Add(-24 * time.Hour),
+			NextReview: testNow.Add(-24 * time.Hour),
 			EaseFactor: 2.5,
 		}
 		if !store.IsDue("past question") {
@@ -181,7 +190,7 @@ func TestRate(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			store := &Store{Cards: make(map[string]CardState)}
+			store := newTestStore()
 			question := "rate test: " + tt.name
 
 			if tt.initInterval > 0 {
@@ -216,16 +225,206 @@ func TestRate(t *testing.T) {
 	}
 }
 
+func TestRateAgain(t *testing.T) {
+	t.Run("learned card lapses into relearning", func(t *testing.T) {
+		store := newTestStore()
+		key := CardKey("forgotten")
+		store.Cards[key] = CardState{
+			Interval:   20,
+			EaseFactor: 2.5,
+			NextReview: testNow.Add(-time.Hour),
+		}
+
+		store.Rate("forgotten", Again)
+
+		state := store.Cards[key]
+		if state.Interval != 1 {
+			t.Errorf("interval = %d, want 1", state.Interval)
+		}
+		if state.Lapses != 1 {
+			t.Errorf("lapses = %d, want 1", state.Lapses)
+		}
+		if !state.Relearning {
+			t.Error("card should be relearning")
+		}
+		if state.EaseFactor < 2.29 || state.EaseFactor > 2.31 {
+			t.Errorf("ease factor = %f, want ~2.3", state.EaseFactor)
+		}
+		if !store.IsDue("forgotten") {
+			t.Error("lapsed card should be due immediately")
+		}
+	})
+
+	t.Run("repeated again while relearning counts one lapse", func(t *testing.T) {
+		store := newTestStore()
+		key := CardKey("forgotten twice")
+		store.Cards[key] = CardState{Interval: 10, EaseFactor: 2.5}
+
+		store.Rate("forgotten twice", Again)
+		store.Rate("forgotten twice", Again)
+
+		if got := store.Cards[key].Lapses; got != 1 {
+			t.Errorf("lapses = %d, want 1", got)
+		}
+	})
+
+	t.Run("new card does not lapse", func(t *testing.T) {
+		store := newTestStore()
+		store.Rate("brand new", Again)
+
+		if got := store.Cards[CardKey("brand new")].Lapses; got != 0 {
+			t.Errorf("lapses = %d, want 0", got)
+		}
+	})
+
+	t.Run("good graduates relearning card at one day", func(t *testing.T) {
+		store := newTestStore()
+		key := CardKey("relearned")
+		store.Cards[key] = CardState{Interval: 10, EaseFactor: 2.5}
+
+		store.Rate("relearned", Again)
+		store.Rate("relearned", Good)
+
+		state := store.Cards[key]
+		if state.Relearning {
+			t.Error("card should have graduated from relearning")
+		}
+		if state.Interval != 1 {
+			t.Errorf("interval = %d, want 1", state.Interval)
+		}
+		if store.IsDue("relearned") {
+			t.Error("graduated card should not be due today")
+		}
+	})
+}
+
+func TestPreview(t *testing.T) {
+	store := newTestStore()
+	store.Cards[CardKey("preview")] = CardState{Interval: 10, EaseFactor: 2.5}
+
+	got := store.Preview("preview")
+	want := map[Rating]int{Again: 0, Hard: 10, Good: 25, Easy: 32}
+	for r, days := range want {
+		if got[r] != days {
+			t.Errorf("preview[%d] = %d, want %d", r, got[r], days)
+		}
+	}
+	if store.Cards[CardKey("preview")].LastReviewed != (time.Time{}) {
+		t.Error("Preview must not modify stored state")
+	}
+}
+
+type fakeScheduler struct{ rated []Rating }
+
+func (f *fakeScheduler) Rate(state CardState, rating Rating, now time.Time) CardState {
+	f.rated = append(f.rated, rating)
+	state.NextReview = now.AddDate(1, 0, 0)
+	return state
+}
+
+func (f *fakeScheduler) IsDue(state CardState, now time.Time) bool { return false }
+
+func (f *fakeScheduler) Preview(state CardState, now time.Time) map[Rating]int { return nil }
+
+func TestSetScheduler(t *testing.T) {
+	store := newTestStore()
+	fake := &fakeScheduler{}
+	store.SetScheduler(fake)
+
+	if store.IsDue("anything") {
+		t.Error("IsDue should delegate to the scheduler")
+	}
+	store.Rate("anything", Good)
+	if len(fake.rated) != 1 || fake.rated[0] != Good {
+		t.Errorf("scheduler rated %v, want [Good]", fake.rated)
+	}
+	if got := store.Cards[CardKey("anything")].NextReview; !got.Equal(testNow.AddDate(1, 0, 0)) {
+		t.Errorf("next review = %v, want one year after the injected clock", got)
+	}
+}
+
+func TestSetCardScheduler(t *testing.T) {
+	t.Run("only the overridden card uses it", func(t *testing.T) {
+		store := newTestStore()
+		fake := &fakeScheduler{}
+		store.SetCardScheduler("special", fake)
+
+		store.Rate("special", Easy)
+		store.Rate("regular", Good)
+		if len(fake.rated) != 1 || fake.rated[0] != Easy {
+			t.Errorf("override rated %v, want [Easy]", fake.rated)
+		}
+		want := SM2Scheduler{}.Rate(CardState{EaseFactor: 2.5}, Good, testNow)
+		if got := store.GetState("regular"); got.Interval != want.Interval {
+			t.Errorf("regular card interval = %d, want %d from SM-2", got.Interval, want.Interval)
+		}
+	})
+
+	t.Run("switching to fsrs migrates SM-2 state", func(t *testing.T) {
+		store := newTestStore()
+		store.Cards[CardKey("q")] = CardState{Interval: 10, EaseFactor: 2.5, NextReview: testNow}
+		store.SetCardScheduler("q", NewFSRSScheduler(0.9))
+
+		if got := store.GetState("q").Stability; got != 10 {
+			t.Errorf("stability = %v, want 10", got)
+		}
+	})
+}
+
+func TestUndo(t *testing.T) {
+	t.Run("nothing to undo", func(t *testing.T) {
+		store := newTestStore()
+		if ok, err := store.Undo(); ok || err != nil {
+			t.Errorf("Undo() = %v, %v, want false, nil", ok, err)
+		}
+	})
+
+	t.Run("new card becomes new again", func(t *testing.T) {
+		store := newTestStore()
+		store.Rate("q", Good)
+		if ok, err := store.Undo(); !ok || err != nil {
+			t.Fatalf("Undo() = %v, %v, want true, nil", ok, err)
+		}
+		if !store.IsNew("q") {
+			t.Error("card still has state after undo")
+		}
+		if len(store.Log) != 0 {
+			t.Errorf("log has %d events, want 0", len(store.Log))
+		}
+	})
+
+	t.Run("ratings are undone last first", func(t *testing.T) {
+		store := newTestStore()
+		before := CardState{Interval: 10, EaseFactor: 2.5, NextReview: testNow}
+		store.Cards[CardKey("q")] = before
+		store.Rate("q", Again)
+		afterAgain := store.GetState("q")
+		store.Rate("q", Easy)
+
+		for _, want := range []CardState{afterAgain, before} {
+			if _, err := store.Undo(); err != nil {
+				t.Fatalf("Undo() error: %v", err)
+			}
+			if got := store.GetState("q"); got != want {
+				t.Errorf("state = %+v, want %+v", got, want)
+			}
+		}
+		if ok, _ := store.Undo(); ok {
+			t.Error("Undo() succeeded with nothing left to undo")
+		}
+	})
+}
+
 func TestIsNew(t *testing.T) {
 	t.Run("unreviewed card is new", func(t *testing.T) {
-		store := &Store{Cards: make(map[string]CardState)}
+		store := newTestStore()
 		if !store.IsNew("never seen") {
 			t.Error("unreviewed card should be new")
 		}
 	})
 
 	t.Run("reviewed card is not new", func(t *testing.T) {
-		store := &Store{Cards: make(map[string]CardState)}
+		store := newTestStore()
 		store.Rate("reviewed card", Good)
 		if store.IsNew("reviewed card") {
 			t.Error("reviewed card should not be new")
@@ -235,17 +434,17 @@ func TestIsNew(t *testing.T) {
 
 func TestIsOverdue(t *testing.T) {
 	t.Run("new card is not overdue", func(t *testing.T) {
-		store := &Store{Cards: make(map[string]CardState)}
+		store := newTestStore()
 		if store.IsOverdue("new card") {
 			t.Error("new card should not be overdue")
 		}
 	})
 
 	t.Run("card due more than one day ago is overdue", func(t *testing.T) {
-		store := &Store{Cards: make(map[string]CardState)}
+		store := newTestStore()
 		key := CardKey("old card")
 		store.Cards[key] = CardState{
-			NextReview: time.Now().Add(-72 * time.Hour),
+			NextReview: testNow.Add(-72 * time.Hour),
 			EaseFactor: 2.5,
 		}
 		if !store.IsOverdue("old card") {
@@ -254,10 +453,10 @@ func TestIsOverdue(t *testing.T) {
 	})
 
 	t.Run("recently due card is not overdue", func(t *testing.T) {
-		store := &Store{Cards: make(map[string]CardState)}
+		store := newTestStore()
 		key := CardKey("recent card")
 		store.Cards[key] = CardState{
-			NextReview: time.Now().Add(-1 * time.Hour),
+			NextReview: testNow.Add(-1 * time.Hour),
 			EaseFactor: 2.5,
 		}
 		if store.IsOverdue("recent card") {
@@ -268,16 +467,16 @@ func TestIsOverdue(t *testing.T) {
 
 func TestStreak(t *testing.T) {
 	t.Run("empty store", func(t *testing.T) {
-		store := &Store{Cards: make(map[string]CardState)}
+		store := newTestStore()
 		if got := store.Streak(); got != 0 {
 			t.Errorf("streak = %d, want 0", got)
 		}
 	})
 
 	t.Run("single day today", func(t *testing.T) {
-		store := &Store{Cards: make(map[string]CardState)}
+		store := newTestStore()
 		store.Cards["a"] = CardState{
-			LastReviewed: time.Now(),
+			LastReviewed: testNow,
 		}
 		if got := store.Streak(); got != 1 {
 			t.Errorf("streak = %d, want 1", got)
@@ -285,8 +484,8 @@ func TestStreak(t *testing.T) {
 	})
 
 	t.Run("consecutive days", func(t *testing.T) {
-		store := &Store{Cards: make(map[string]CardState)}
-		now := time.Now()
+		store := newTestStore()
+		now := testNow
 		store.Cards["a"] = CardState{
 			LastReviewed: now,
 		}
@@ -302,8 +501,8 @@ func TestStreak(t *testing.T) {
 	})
 
 	t.Run("gap breaks streak", func(t *testing.T) {
-		store := &Store{Cards: make(map[string]CardState)}
-		now := time.Now()
+		store := newTestStore()
+		now := testNow
 		store.Cards["a"] = CardState{
 			LastReviewed: now,
 		}
@@ -318,8 +517,8 @@ func TestStreak(t *testing.T) {
 }
 
 func TestReviewedToday(t *testing.T) {
-	store := &Store{Cards: make(map[string]CardState)}
-	now := time.Now()
+	store := newTestStore()
+	now := testNow
 
 	store.Cards[CardKey("q1")] = CardState{
 		LastReviewed: now,
@@ -335,12 +534,12 @@ func TestReviewedToday(t *testing.T) {
 }
 
 func TestDueCount(t *testing.T) {
-	store := &Store{Cards: make(map[string]CardState)}
+	store := newTestStore()
 
 	// q1 is new (due)
 	// q2 has future review (not due)
 	store.Cards[CardKey("q2")] = CardState{
-		NextReview: time.Now().Add(48 * time.Hour),
+		NextReview: testNow.Add(48 * time.Hour),
 	}
 
 	got := store.DueCount([]string{"q1", "q2"})
@@ -0,0 +1,159 @@
+package tui
+
+import (
+	"regexp"
+	"strings"
+
+	chromastyles "github.com/alecthomas/chroma/v2/styles"
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/glamour/ansi"
+	"github.com/charmbracelet/glamour/styles"
+	"github.com/charmbracelet/lipgloss"
+	xansi "github.com/charmbracelet/x/ansi"
+	"github.com/muesli/termenv"
+)
+
+// defaultWidth is used until the terminal reports its size.
+const defaultWidth = 80
+
+// Cloze answers mark revealed text as ==text==, which markdown doesn't know.
+// Before rendering, the marks become private-use runes that pass through
+// glamour untouched, and are turned into highlights afterwards.
+const (
+	highlightOpen  = "\uE000"
+	highlightClose = "\uE001"
+)
+
+var markedRe = regexp.MustCompile(highlightOpen + `(?s)(.*?)` + highlightClose)
+
+// markdown renders card text for the terminal, wrapped to a width. Question
+// and answer keep their own colours. If plain is set, or glamour fails,
+// text is shown as-is with only highlights and wrapping.
+type markdown struct {
+	width     int
+	plain     bool   // as configured
+	failed    bool   // glamour could not be set up; render as if plain
+	codeTheme string // chroma style for fenced code; empty for glamour's own
+	question  *glamour.TermRenderer
+	answer    *glamour.TermRenderer
+}
+
+func newMarkdown(width int, plain bool, codeTheme string) *markdown {
+	md := &markdown{width: width, plain: plain, codeTheme: codeTheme}
+	if plain {
+		return md
+	}
+
+	base := styles.LightStyleConfig
+	if lipgloss.HasDarkBackground() {
+		base = styles.DarkStyleConfig
+	}
+	profile := lipgloss.ColorProfile()
+	base = codeStyle(base, codeTheme, profile)
+	opts := []glamour.TermRendererOption{
+		glamour.WithWordWrap(width),
+		glamour.WithColorProfile(profile),
+		glamour.WithChromaFormatter(chromaFormatter(profile)),
+	}
+
+	var err error
+	if md.question, err = glamour.NewTermRenderer(append(opts, glamour.WithStyles(cardStyle(base, "15", true)))...); err != nil {
+		md.failed = true
+		return md
+	}
+	if md.answer, err = glamour.NewTermRenderer(append(opts, glamour.WithStyles(cardStyle(base, "10", false)))...); err != nil {
+		md.failed = true
+	}
+	return md
+}
+
+// resize returns a renderer like md for a new terminal width.
+func (md *markdown) resize(width int) *markdown {
+	if width <= 0 || width == md.width {
+		return md
+	}
+	return newMarkdown(width, md.plain, md.codeTheme)
+}
+
+// codeStyle sets how fenced code is highlighted. A known chroma theme
+// replaces glamour's built-in colours; without colour support code is
+// left unhighlighted rather than turned into escape-code noise.
+func codeStyle(base ansi.StyleConfig, theme string, profile termenv.Profile) ansi.StyleConfig {
+	style := base
+	switch {
+	case profile == termenv.Ascii:
+		style.CodeBlock.Chroma = nil
+		style.CodeBlock.Theme = ""
+	case theme != "":
+		if _, ok := chromastyles.Registry[strings.ToLower(theme)]; ok {
+			style.CodeBlock.Chroma = nil
+			style.CodeBlock.Theme = strings.ToLower(theme)
+		}
+	}
+	return style
+}
+
+// chromaFormatter picks the chroma output for the terminal's colours, so
+// themes degrade to the nearest of 256 or 16 colours instead of emitting
+// true-colour codes the terminal can't show.
+func chromaFormatter(profile termenv.Profile) string {
+	switch profile {
+	case termenv.TrueColor:
+		return "terminal16m"
+	case termenv.ANSI256:
+		return "terminal256"
+	case termenv.ANSI:
+		return "terminal16"
+	default:
+		return "noop"
+	}
+}
+
+// cardStyle adapts a glamour style to sit inside the card view: no margin
+// or surrounding blank lines, and the given text colour.
+func cardStyle(base ansi.StyleConfig, color string, bold bool) ansi.StyleConfig {
+	style := base
+	var margin uint
+	style.Document.Margin = &margin
+	style.Document.BlockPrefix = ""
+	style.Document.BlockSuffix = ""
+	style.Document.Color = &color
+	if bold {
+		style.Document.Bold = &bold
+	}
+	return style
+}
+
+// renderQuestion renders a card's question.
+func (md *markdown) renderQuestion(text string) string {
+	return md.render(md.question, text, questionStyle)
+}
+
+// renderAnswer renders a card's answer.
+func (md *markdown) renderAnswer(text string) string {
+	return md.render(md.answer, text, answerStyle)
+}
+
+func (md *markdown) render(r *glamour.TermRenderer, text string, fallback lipgloss.Style) string {
+	if !md.plain && !md.failed {
+		marked := highlightRe.ReplaceAllString(text, highlightOpen+"$1"+highlightClose)
+		if out, err := r.Render(marked); err == nil {
+			return strings.Trim(renderMarked(out), "\n")
+		}
+	}
+	return xansi.Wordwrap(renderHighlights(text, fallback), md.width, "")
+}
+
+// renderMarked replaces each marked span in glamour's output with the
+// highlight style, a line at a time in case the span was wrapped.
+func renderMarked(out string) string {
+	return markedRe.ReplaceAllStringFunc(out, func(span string) string {
+		inner := xansi.Strip(markedRe.FindStringSubmatch(span)[1])
+		lines := strings.Split(inner, "\n")
+		for i, line := range lines {
+			lead := len(line) - len(strings.TrimLeft(line, " "))
+			lines[i] = line[:lead] + highlightStyle.Render(line[lead:])
+		}
+		return strings.Join(lines, "\n")
+	})
+}
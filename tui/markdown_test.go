@@ -0,0 +1,121 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/glamour/styles"
+	"github.com/charmbracelet/lipgloss"
+	xansi "github.com/charmbracelet/x/ansi"
+	"github.com/muesli/termenv"
+)
+
+// withColor renders in ANSI colours for the rest of the test, so styles
+// show up in the output.
+func withColor(t *testing.T) {
+	t.Helper()
+	prev := lipgloss.ColorProfile()
+	lipgloss.SetColorProfile(termenv.ANSI)
+	t.Cleanup(func() { lipgloss.SetColorProfile(prev) })
+}
+
+func TestMarkdownPlainText(t *testing.T) {
+	const text = "Use **make** to build a `chan int` for the worker pool"
+
+	tests := []struct {
+		name string
+		md   *markdown
+	}{
+		{"configured", &markdown{width: 20, plain: true}},
+		{"glamour failed", &markdown{width: 20, failed: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := xansi.Strip(tt.md.renderAnswer(text))
+			if got := strings.Join(strings.Fields(out), " "); got != text {
+				t.Errorf("plain output = %q, want the text as-is", got)
+			}
+			for _, line := range strings.Split(out, "\n") {
+				if w := xansi.StringWidth(line); w > 20 {
+					t.Errorf("line %q is %d wide, want at most 20", line, w)
+				}
+			}
+		})
+	}
+}
+
+func TestMarkdownKeepsHighlights(t *testing.T) {
+	withColor(t)
+	md := newMarkdown(40, false, "")
+	if md.failed {
+		t.Fatal("glamour renderer could not be set up")
+	}
+
+	out := md.renderAnswer("Paris is the ==capital== of **France**")
+	if !strings.Contains(out, highlightStyle.Render("capital")) {
+		t.Errorf("output %q does not highlight the marked text", out)
+	}
+	if plain := xansi.Strip(out); strings.ContainsAny(plain, "="+highlightOpen+highlightClose) || strings.Contains(plain, "**") {
+		t.Errorf("output %q still has markup in it", plain)
+	}
+}
+
+func TestRenderMarked(t *testing.T) {
+	withColor(t)
+	in := "a " + highlightOpen + "one\n  two" + highlightClose + " b"
+	want := "a " + highlightStyle.Render("one") + "\n  " + highlightStyle.Render("two") + " b"
+
+	if got := renderMarked(in); got != want {
+		t.Errorf("renderMarked() = %q, want %q", got, want)
+	}
+}
+
+func TestChromaFormatter(t *testing.T) {
+	tests := []struct {
+		profile termenv.Profile
+		want    string
+	}{
+		{termenv.TrueColor, "terminal16m"},
+		{termenv.ANSI256, "terminal256"},
+		{termenv.ANSI, "terminal16"},
+		{termenv.Ascii, "noop"},
+	}
+
+	for _, tt := range tests {
+		if got := chromaFormatter(tt.profile); got != tt.want {
+			t.Errorf("chromaFormatter(%v) = %q, want %q", tt.profile, got, tt.want)
+		}
+	}
+}
+
+func TestCodeStyle(t *testing.T) {
+	base := styles.DarkStyleConfig
+
+	tests := []struct {
+		name       string
+		theme      string
+		profile    termenv.Profile
+		wantTheme  string
+		wantChroma bool // glamour's built-in colours kept
+	}{
+		{"no theme", "", termenv.ANSI256, "", true},
+		{"unknown theme", "no-such-theme", termenv.ANSI256, "", true},
+		{"known theme", "monokai", termenv.ANSI256, "monokai", false},
+		{"mixed case theme", "Monokai", termenv.ANSI, "monokai", false},
+		{"no colour", "monokai", termenv.Ascii, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := codeStyle(base, tt.theme, tt.profile).CodeBlock
+			if got.Theme != tt.wantTheme || (got.Chroma != nil) != tt.wantChroma {
+				t.Errorf("code block theme %q, chroma set %v; want %q, %v",
+					got.Theme, got.Chroma != nil, tt.wantTheme, tt.wantChroma)
+			}
+		})
+	}
+	if base.CodeBlock.Chroma == nil {
+		t.Error("codeStyle changed the style it was given")
+	}
+}
@@ -2,11 +2,20 @@ package tui
 
 import (
 	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"sort"
 	"strings"
+	"time"
 
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/michal-franc/ankies-franc/config"
 	"github.com/michal-franc/ankies-franc/parser"
 	"github.com/michal-franc/ankies-franc/storage"
 )
@@ -27,6 +36,26 @@ type deckInfo struct {
 	selected bool
 }
 
+// ReloadFunc re-reads a notes file after it was edited during review and
+// returns the cards to review, the file's cards keeping their schedules.
+type ReloadFunc func(path string) ([]parser.Card, error)
+
+// editorClosedMsg reports that the editor opened on a card has exited.
+type editorClosedMsg struct {
+	path string
+	took time.Duration // time spent in the editor
+	err  error
+}
+
+// reviewStep is where the session stood when a card was rated, so undo can
+// go back to it.
+type reviewStep struct {
+	current int
+	queued  int // len(cards) before the rating; Again queues the card again
+	shownAt time.Time
+	typed   *typedAnswer
+}
+
 type Model struct {
 	allCards []parser.Card
 	cards    []parser.Card
@@ -36,13 +65,26 @@ type Model struct {
 	total    int
 	reviewed int
 	quitting bool
+	shownAt  time.Time // when the current question was first shown
+	err      error     // last failure to save a rating or edit a card
+
+	md *markdown // card rendering
+
+	// typed answers
+	typeAnswerDecks func(deck string) bool // decks whose answers are always typed
+	input           textinput.Model
+	typed           *typedAnswer // the checked answer; nil if none was typed
+
+	history []reviewStep // ratings made this session, for undo
+
+	reload ReloadFunc // nil if cards can't be edited
 
 	// deck picker
 	decks  []deckInfo
 	cursor int
 }
 
-func New(cards []parser.Card, store *storage.Store) Model {
+func New(cards []parser.Card, store *storage.Store, cfg config.Config) Model {
 	// Build deck info
 	deckMap := make(map[string]*deckInfo)
 	for _, c := range cards {
@@ -52,7 +94,7 @@ func New(cards []parser.Card, store *storage.Store) Model {
 			deckMap[c.DeckName] = d
 		}
 		d.total++
-		if store.IsDue(c.Question) {
+		if store.IsDue(c.Key()) {
 			d.due++
 		}
 	}
@@ -70,19 +112,48 @@ func New(cards []parser.Card, store *storage.Store) Model {
 		store:    store,
 		state:    pickingDecks,
 		decks:    decks,
+		md:       newMarkdown(defaultWidth, cfg.PlainText, cfg.CodeTheme),
+
+		typeAnswerDecks: cfg.IsTypeAnswerDeck,
+		input:           newAnswerInput(),
 	}
 }
 
+// WithReload lets the review open the current card in $EDITOR, reading its
+// file again with reload once the editor exits.
+func (m Model) WithReload(reload ReloadFunc) Model {
+	m.reload = reload
+	return m
+}
+
+func newAnswerInput() textinput.Model {
+	input := textinput.New()
+	input.Placeholder = "type the answer"
+	input.Width = defaultWidth - len(input.Prompt)
+	_ = input.Cursor.SetMode(cursor.CursorStatic)
+	return input
+}
+
 func (m Model) Init() tea.Cmd {
 	return nil
 }
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
+	case editorClosedMsg:
+		return m.afterEdit(msg), nil
+	case tea.WindowSizeMsg:
+		m.md = m.md.resize(msg.Width)
+		if msg.Width > len(m.input.Prompt) {
+			m.input.Width = msg.Width - len(m.input.Prompt)
+		}
+		return m, nil
 	case tea.KeyMsg:
-		switch m.state {
-		case pickingDecks:
+		switch {
+		case m.state == pickingDecks:
 			return m.updateDeckPicker(msg)
+		case m.typing():
+			return m.updateTyping(msg)
 		default:
 			return m.updateReview(msg)
 		}
@@ -137,12 +208,19 @@ func (m Model) startReview() Model {
 		}
 	}
 
-	// Filter to due cards in selected decks
+	// Filter to due cards in selected decks. Only one half of a reversible
+	// pair is shown per session; the other stays due for the next one.
 	var dueCards []parser.Card
+	queued := make(map[string]bool)
 	for _, c := range m.allCards {
-		if selected[c.DeckName] && m.store.IsDue(c.Question) {
-			dueCards = append(dueCards, c)
+		if !selected[c.DeckName] || !m.store.IsDue(c.Key()) {
+			continue
 		}
+		if c.Sibling != "" && queued[c.Sibling] {
+			continue
+		}
+		queued[c.Key()] = true
+		dueCards = append(dueCards, c)
 	}
 
 	m.cards = dueCards
@@ -152,12 +230,66 @@ func (m Model) startReview() Model {
 	if len(dueCards) == 0 {
 		m.state = done
 	} else {
-		m.state = showingQuestion
+		m = m.showQuestion()
 	}
 
 	return m
 }
 
+// showQuestion shows the current card's question, with an empty answer
+// input if the answer is to be typed.
+func (m Model) showQuestion() Model {
+	m.state = showingQuestion
+	m.shownAt = time.Now()
+	m.typed = nil
+	m.input.Reset()
+	if m.typesAnswer(m.cards[m.current]) {
+		m.input.Focus()
+	} else {
+		m.input.Blur()
+	}
+	return m
+}
+
+// typesAnswer reports whether the card's answer is typed in before the
+// flip, because of a #type-answer tag or because its deck is configured so.
+func (m Model) typesAnswer(card parser.Card) bool {
+	return card.TypeAnswer || m.typeAnswerDecks != nil && m.typeAnswerDecks(card.DeckName)
+}
+
+// typing reports whether keys go to the answer input.
+func (m Model) typing() bool {
+	return m.state == showingQuestion && m.input.Focused()
+}
+
+// updateTyping handles keys while the answer is typed: enter checks it and
+// flips the card, esc flips without checking.
+func (m Model) updateTyping(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+
+	case "ctrl+z":
+		return m.undo(), nil
+
+	case "enter":
+		m.typed = checkAnswer(m.input.Value(), m.cards[m.current].Answer)
+		m.input.Blur()
+		m.state = showingAnswer
+		return m, nil
+
+	case "esc":
+		m.input.Blur()
+		m.state = showingAnswer
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
 func (m Model) updateReview(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "q", "ctrl+c":
@@ -169,37 +301,168 @@ func (m Model) updateReview(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.state = showingAnswer
 		}
 
+	case "u", "ctrl+z":
+		m = m.undo()
+
+	case "o":
+		if m.reload != nil && (m.state == showingQuestion || m.state == showingAnswer) {
+			return m, m.openEditor()
+		}
+
+	case "enter":
+		if m.state == showingAnswer && m.typed != nil {
+			m = m.rate(m.typed.suggested)
+		}
+
+	case "0", "a":
+		if m.state == showingAnswer {
+			m = m.rate(storage.Again)
+		}
+
 	case "1", "h":
 		if m.state == showingAnswer {
-			m.store.Rate(m.cards[m.current].Question, storage.Hard)
-			m.reviewed++
-			m = m.advance()
+			m = m.rate(storage.Hard)
 		}
 
 	case "2", "g":
 		if m.state == showingAnswer {
-			m.store.Rate(m.cards[m.current].Question, storage.Good)
-			m.reviewed++
-			m = m.advance()
+			m = m.rate(storage.Good)
 		}
 
 	case "3", "e":
 		if m.state == showingAnswer {
-			m.store.Rate(m.cards[m.current].Question, storage.Easy)
-			m.reviewed++
-			m = m.advance()
+			m = m.rate(storage.Easy)
 		}
 	}
 
 	return m, nil
 }
 
+// rate records the rating for the current card, with the time taken since
+// the question was shown, commits it to disk right away and moves on. A
+// forgotten card comes back at the end of the session.
+func (m Model) rate(rating storage.Rating) Model {
+	m.history = append(m.history, reviewStep{current: m.current, queued: len(m.cards), shownAt: m.shownAt, typed: m.typed})
+	if rating == storage.Again {
+		m.cards = append(m.cards, m.cards[m.current])
+		m.total = len(m.cards)
+	}
+	m.store.RateTimed(m.cards[m.current].Key(), rating, time.Since(m.shownAt))
+	m.err = nil
+	if err := m.store.Commit(); err != nil {
+		m.err = fmt.Errorf("could not save rating: %w", err)
+	}
+	m.reviewed++
+	return m.advance()
+}
+
+// undo takes back the session's last rating and returns to that card with
+// its answer shown, to be rated again. It works from the done screen too.
+func (m Model) undo() Model {
+	if len(m.history) == 0 {
+		return m
+	}
+	ok, err := m.store.Undo()
+	if err != nil {
+		m.err = fmt.Errorf("could not undo rating: %w", err)
+		return m
+	}
+	if !ok {
+		return m
+	}
+
+	step := m.history[len(m.history)-1]
+	m.history = m.history[:len(m.history)-1]
+	m.cards = m.cards[:step.queued]
+	m.total = len(m.cards)
+	m.current = step.current
+	m.reviewed--
+	m.state = showingAnswer
+	m.shownAt = step.shownAt
+	m.typed = step.typed
+	m.input.Blur()
+	return m
+}
+
+// openEditor suspends the review and opens the current card's file in
+// $EDITOR at the question's line.
+func (m Model) openEditor() tea.Cmd {
+	card := m.cards[m.current]
+	editor := strings.Fields(os.Getenv("EDITOR"))
+	if len(editor) == 0 {
+		editor = []string{"vi"}
+	}
+	args := append(editor[1:], fmt.Sprintf("+%d", max(card.QuestionLines.Start, 1)), card.SourceFile)
+	cmd := exec.Command(editor[0], args...)
+
+	opened := time.Now()
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return editorClosedMsg{path: card.SourceFile, took: time.Since(opened), err: err}
+	})
+}
+
+// afterEdit reads the edited file again and carries on with the new version
+// of each queued card. Time in the editor doesn't count towards answering.
+// Ratings made before the edit can no longer be undone: their cards' keys
+// may have changed.
+func (m Model) afterEdit(msg editorClosedMsg) Model {
+	m.shownAt = m.shownAt.Add(msg.took)
+	if msg.err != nil {
+		m.err = fmt.Errorf("could not run editor: %w", msg.err)
+		return m
+	}
+	cards, err := m.reload(msg.path)
+	if err != nil {
+		m.err = fmt.Errorf("could not reload %s: %w", msg.path, err)
+		return m
+	}
+
+	queue := slices.Clone(m.cards[:m.current])
+	kept := false
+	for i, c := range m.cards[m.current:] {
+		if updated, ok := updatedCard(cards, c); ok {
+			queue = append(queue, updated)
+			kept = kept || i == 0
+		}
+	}
+	m.allCards = cards
+	m.cards = queue
+	m.total = len(queue)
+	m.history = nil
+
+	switch {
+	case m.current >= len(m.cards):
+		m.state = done
+	case !kept:
+		m = m.showQuestion() // the card was deleted; on to the next
+	case m.typed != nil:
+		m.typed = checkAnswer(m.input.Value(), m.cards[m.current].Answer)
+	}
+	return m
+}
+
+// updatedCard finds the new version of old among cards: the card with the
+// same key or, if its key changed, the one at the same place in its file.
+func updatedCard(cards []parser.Card, old parser.Card) (parser.Card, bool) {
+	for _, c := range cards {
+		if c.Key() == old.Key() {
+			return c, true
+		}
+	}
+	for _, c := range cards {
+		if c.SourceFile == old.SourceFile && c.Position == old.Position {
+			return c, true
+		}
+	}
+	return parser.Card{}, false
+}
+
 func (m Model) advance() Model {
 	m.current++
 	if m.current >= len(m.cards) {
 		m.state = done
 	} else {
-		m.state = showingQuestion
+		m = m.showQuestion()
 	}
 	return m
 }
@@ -225,10 +488,36 @@ var (
 	hintStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("241"))
 
+	sourceStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("240")).
+			Italic(true)
+
 	doneStyle = lipgloss.NewStyle().
 			Bold(true).
 			Foreground(lipgloss.Color("10"))
 
+	highlightStyle = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("0")).
+			Background(lipgloss.Color("11"))
+
+	warningStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("11"))
+
+	errorStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("9"))
+
+	diffExtraStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("9")).
+			Strikethrough(true)
+
+	diffMissingStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("0")).
+				Background(lipgloss.Color("11"))
+
+	ratingAgainStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("13"))
+
 	ratingHardStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("9"))
 
@@ -268,10 +557,24 @@ func (m Model) View() string {
 	case pickingDecks:
 		return m.viewDeckPicker()
 	case done:
-		return doneStyle.Render(fmt.Sprintf("Done for today! Reviewed %d cards.\n", m.reviewed))
+		return doneStyle.Render(fmt.Sprintf("Done for today! Reviewed %d cards.\n", m.reviewed)) + m.viewUndoHint() + m.viewError()
 	default:
-		return m.viewCard()
+		return m.viewCard() + m.viewError()
+	}
+}
+
+func (m Model) viewUndoHint() string {
+	if len(m.history) == 0 {
+		return ""
+	}
+	return hintStyle.Render("[u] undo last rating  [q] quit") + "\n"
+}
+
+func (m Model) viewError() string {
+	if m.err == nil {
+		return ""
 	}
+	return errorStyle.Render("Error: "+m.err.Error()) + "\n"
 }
 
 func (m Model) viewDeckPicker() string {
@@ -330,33 +633,112 @@ func (m Model) viewCard() string {
 		deckStyle.Render(card.DeckName),
 		progressStyle.Render(fmt.Sprintf("%d/%d", m.current+1, m.total)),
 	)
+	if card.Qualifier != "" {
+		header += "  " + warningStyle.Render("duplicate question, tracked by "+card.Qualifier)
+	}
 	b.WriteString(header)
 	b.WriteString("\n")
 	b.WriteString(separatorStyle.Render(strings.Repeat("─", 50)))
 	b.WriteString("\n\n")
 
-	b.WriteString(questionStyle.Render(card.Question))
+	b.WriteString(m.md.renderQuestion(card.Question))
 	b.WriteString("\n")
 
 	if m.state == showingAnswer {
 		b.WriteString("\n")
 		b.WriteString(separatorStyle.Render("───"))
 		b.WriteString("\n\n")
-		b.WriteString(answerStyle.Render(card.Answer))
+		b.WriteString(m.md.renderAnswer(card.Answer))
 		b.WriteString("\n\n")
+		if m.typed != nil {
+			b.WriteString(hintStyle.Render("typed: "))
+			b.WriteString(m.typed.render())
+			b.WriteString("\n\n")
+		}
+		b.WriteString(sourceStyle.Render(cardSource(card)))
+		b.WriteString("\n")
 		b.WriteString(separatorStyle.Render(strings.Repeat("─", 50)))
 		b.WriteString("\n")
-		b.WriteString(fmt.Sprintf("%s  %s  %s",
-			ratingHardStyle.Render("[1/h] Hard"),
-			ratingGoodStyle.Render("[2/g] Good"),
-			ratingEasyStyle.Render("[3/e] Easy"),
+		preview := m.store.Preview(card.Key())
+		b.WriteString(fmt.Sprintf("%s  %s  %s  %s",
+			ratingAgainStyle.Render("[0/a] Again "+formatInterval(preview[storage.Again])),
+			ratingHardStyle.Render("[1/h] Hard "+formatInterval(preview[storage.Hard])),
+			ratingGoodStyle.Render("[2/g] Good "+formatInterval(preview[storage.Good])),
+			ratingEasyStyle.Render("[3/e] Easy "+formatInterval(preview[storage.Easy])),
 		))
 		b.WriteString("\n")
+		var hints []string
+		if m.typed != nil {
+			hints = append(hints, "[enter] "+ratingName(m.typed.suggested)+" (suggested)")
+		}
+		if m.reload != nil {
+			hints = append(hints, "[o] edit")
+		}
+		if len(m.history) > 0 {
+			hints = append(hints, "[u] undo")
+		}
+		if len(hints) > 0 {
+			b.WriteString(hintStyle.Render(strings.Join(hints, "  ")))
+			b.WriteString("\n")
+		}
+	} else if m.typing() {
+		b.WriteString("\n")
+		b.WriteString(m.input.View())
+		b.WriteString("\n\n")
+		undo := ""
+		if len(m.history) > 0 {
+			undo = "  [ctrl+z] undo"
+		}
+		b.WriteString(hintStyle.Render("[enter] check  [esc] show answer" + undo + "  [ctrl+c] quit"))
+		b.WriteString("\n")
 	} else {
 		b.WriteString("\n")
-		b.WriteString(hintStyle.Render("[space] flip  [q] quit"))
+		hints := []string{"[space] flip"}
+		if m.reload != nil {
+			hints = append(hints, "[o] edit")
+		}
+		if len(m.history) > 0 {
+			hints = append(hints, "[u] undo")
+		}
+		b.WriteString(hintStyle.Render(strings.Join(append(hints, "[q] quit"), "  ")))
 		b.WriteString("\n")
 	}
 
 	return b.String()
 }
+
+var highlightRe = regexp.MustCompile(`==([^=\n]+)==`)
+
+// renderHighlights renders text in style, with ==marked== spans (such as
+// revealed cloze deletions) in highlightStyle.
+func renderHighlights(text string, style lipgloss.Style) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range highlightRe.FindAllStringSubmatchIndex(text, -1) {
+		if m[0] > last {
+			b.WriteString(style.Render(text[last:m[0]]))
+		}
+		b.WriteString(highlightStyle.Render(text[m[2]:m[3]]))
+		last = m[1]
+	}
+	if last < len(text) || last == 0 {
+		b.WriteString(style.Render(text[last:]))
+	}
+	return b.String()
+}
+
+// cardSource renders where a card came from: its file and line, then the
+// headings it sits under.
+func cardSource(card parser.Card) string {
+	parts := []string{fmt.Sprintf("%s:%d", filepath.Base(card.SourceFile), card.QuestionLines.Start)}
+	parts = append(parts, card.Headings...)
+	return strings.Join(parts, " › ")
+}
+
+// formatInterval renders a scheduled interval for the rating buttons.
+func formatInterval(days int) string {
+	if days == 0 {
+		return "(now)"
+	}
+	return fmt.Sprintf("(%dd)", days)
+}
@@ -0,0 +1,150 @@
+package tui
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/michal-franc/ankies-franc/storage"
+	"github.com/muesli/termenv"
+)
+
+// maxDiffCells bounds the diff table; longer answers are compared whole.
+const maxDiffCells = 1 << 20
+
+type diffOp int
+
+const (
+	diffSame    diffOp = iota // typed as expected
+	diffMissing               // in the answer, but not typed
+	diffExtra                 // typed, but not in the answer
+)
+
+type diffSpan struct {
+	op   diffOp
+	text string
+}
+
+// typedAnswer is the result of checking a typed answer against the card.
+type typedAnswer struct {
+	spans     []diffSpan
+	suggested storage.Rating
+}
+
+// checkAnswer diffs what was typed against the card's answer, a character
+// at a time and ignoring case, and suggests a rating: Good for a match,
+// Hard for a near miss, Again otherwise.
+func checkAnswer(typed, answer string) *typedAnswer {
+	got, want := []rune(plainAnswer(typed)), []rune(plainAnswer(answer))
+	spans, same := diffRunes(got, want)
+
+	suggested := storage.Again
+	switch {
+	case same == len(got) && same == len(want):
+		suggested = storage.Good
+	case len(got)+len(want) > 0 && float64(2*same)/float64(len(got)+len(want)) >= 0.8:
+		suggested = storage.Hard
+	}
+	return &typedAnswer{spans: spans, suggested: suggested}
+}
+
+// plainAnswer reduces markdown to the text someone would type: inline
+// markup is dropped and whitespace, including line breaks, collapsed.
+func plainAnswer(text string) string {
+	for _, mark := range []string{"**", "__", "==", "`"} {
+		text = strings.ReplaceAll(text, mark, "")
+	}
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// diffRunes returns the edits that turn got into want, from their longest
+// common subsequence, and how many runes the two share.
+func diffRunes(got, want []rune) ([]diffSpan, int) {
+	if (len(got)+1)*(len(want)+1) > maxDiffCells {
+		if strings.EqualFold(string(got), string(want)) {
+			return []diffSpan{{diffSame, string(want)}}, len(want)
+		}
+		return []diffSpan{{diffExtra, string(got)}, {diffMissing, string(want)}}, 0
+	}
+
+	// lcs[i][j] is the common subsequence length of got[i:] and want[j:].
+	lcs := make([][]int, len(got)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(want)+1)
+	}
+	for i := len(got) - 1; i >= 0; i-- {
+		for j := len(want) - 1; j >= 0; j-- {
+			if sameRune(got[i], want[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var spans []diffSpan
+	add := func(op diffOp, r rune) {
+		if n := len(spans); n > 0 && spans[n-1].op == op {
+			spans[n-1].text += string(r)
+			return
+		}
+		spans = append(spans, diffSpan{op, string(r)})
+	}
+	i, j := 0, 0
+	for i < len(got) || j < len(want) {
+		switch {
+		case i < len(got) && j < len(want) && sameRune(got[i], want[j]):
+			add(diffSame, want[j])
+			i++
+			j++
+		case j < len(want) && (i == len(got) || lcs[i][j+1] >= lcs[i+1][j]):
+			add(diffMissing, want[j])
+			j++
+		default:
+			add(diffExtra, got[i])
+			i++
+		}
+	}
+	return spans, lcs[0][0]
+}
+
+func sameRune(a, b rune) bool {
+	return a == b || unicode.ToLower(a) == unicode.ToLower(b)
+}
+
+// render shows the diff in one line: matching text as in the answer,
+// typos struck through and missed text highlighted. Without colour the
+// edits are bracketed instead.
+func (t *typedAnswer) render() string {
+	plain := lipgloss.ColorProfile() == termenv.Ascii
+	var b strings.Builder
+	for _, s := range t.spans {
+		switch {
+		case s.op == diffSame:
+			b.WriteString(answerStyle.Render(s.text))
+		case plain && s.op == diffExtra:
+			b.WriteString("[-" + s.text + "-]")
+		case plain:
+			b.WriteString("{+" + s.text + "+}")
+		case s.op == diffExtra:
+			b.WriteString(diffExtraStyle.Render(s.text))
+		default:
+			b.WriteString(diffMissingStyle.Render(s.text))
+		}
+	}
+	return b.String()
+}
+
+// ratingName is the label of a rating button.
+func ratingName(r storage.Rating) string {
+	switch r {
+	case storage.Again:
+		return "Again"
+	case storage.Hard:
+		return "Hard"
+	case storage.Good:
+		return "Good"
+	default:
+		return "Easy"
+	}
+}
@@ -0,0 +1,98 @@
+package tui
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/michal-franc/ankies-franc/storage"
+)
+
+func TestCheckAnswer(t *testing.T) {
+	tests := []struct {
+		name   string
+		typed  string
+		answer string
+		want   storage.Rating
+	}{
+		{"exact match", "der Hund", "der Hund", storage.Good},
+		{"case and spacing ignored", "  DER   hund ", "der Hund", storage.Good},
+		{"markup stripped from the answer", "make(chan int)", "**`make(chan int)`**", storage.Good},
+		{"answer over several lines", "a typed pipe", "a typed\npipe", storage.Good},
+		{"one typo", "der Hnud", "der Hund", storage.Hard},
+		{"one letter missing", "die Katz", "die Katze", storage.Hard},
+		{"wrong answer", "die Katze", "der Hund", storage.Again},
+		{"nothing typed", "", "der Hund", storage.Again},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkAnswer(tt.typed, tt.answer).suggested; got != tt.want {
+				t.Errorf("checkAnswer(%q, %q) suggests %s, want %s",
+					tt.typed, tt.answer, ratingName(got), ratingName(tt.want))
+			}
+		})
+	}
+}
+
+func TestDiffRunes(t *testing.T) {
+	tests := []struct {
+		name      string
+		got, want string
+		spans     []diffSpan
+		same      int
+	}{
+		{
+			name:  "equal",
+			got:   "hund",
+			want:  "Hund",
+			spans: []diffSpan{{diffSame, "Hund"}},
+			same:  4,
+		},
+		{
+			name:  "missing letter",
+			got:   "Hnd",
+			want:  "Hund",
+			spans: []diffSpan{{diffSame, "H"}, {diffMissing, "u"}, {diffSame, "nd"}},
+			same:  3,
+		},
+		{
+			name:  "extra letter",
+			got:   "Huund",
+			want:  "Hund",
+			spans: []diffSpan{{diffSame, "Hu"}, {diffExtra, "u"}, {diffSame, "nd"}},
+			same:  4,
+		},
+		{
+			name:  "nothing typed",
+			got:   "",
+			want:  "Hund",
+			spans: []diffSpan{{diffMissing, "Hund"}},
+			same:  0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spans, same := diffRunes([]rune(tt.got), []rune(tt.want))
+			if !reflect.DeepEqual(spans, tt.spans) || same != tt.same {
+				t.Errorf("diffRunes(%q, %q) = %v, %d; want %v, %d", tt.got, tt.want, spans, same, tt.spans, tt.same)
+			}
+		})
+	}
+}
+
+func TestDiffRunesTooLong(t *testing.T) {
+	long := []rune(strings.Repeat("x", maxDiffCells))
+
+	spans, same := diffRunes(long, []rune(strings.ToUpper(string(long))))
+	if len(spans) != 1 || spans[0].op != diffSame || same != len(long) {
+		t.Errorf("equal long answers: %d spans, %d same; want one equal span", len(spans), same)
+	}
+
+	spans, same = diffRunes([]rune("y"), long)
+	want := []diffSpan{{diffExtra, "y"}, {diffMissing, string(long)}}
+	if !reflect.DeepEqual(spans, want) || same != 0 {
+		t.Errorf("different long answers are not compared whole: %d spans, %d same", len(spans), same)
+	}
+}